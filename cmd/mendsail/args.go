@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Send options are tokenized like this:
+//
+//   --subject Hello   --subject=Hello   -s Hello
+//
+// An option's value is the next argument unless that is an option itself, so
+// "--subject --to ..." is a missing subject rather than a subject of "--to".
+// "--" ends option parsing and the remaining arguments are taken as values as
+// they are, e.g. "--paragraph -- --help" or "--list -- -a -b". Lists and block
+// sub-options end at the next option, or explicitly at --end. In run, the first
+// "--" starts the command instead, see parseRunArgs.
+
+var shortOptions = shortOptionAliases()
+
+const endOption = "--end"
+
+// argumentError points at the argument that couldn't be parsed.
+type argumentError struct {
+	// Index into the arguments, starting from 0.
+	index int
+	err   error
+}
+
+func (e *argumentError) Error() string {
+	return e.err.Error() + " (argument " + strconv.Itoa(e.index+1) + ")"
+}
+
+func (e *argumentError) Unwrap() error {
+	return e.err
+}
+
+type argScanner struct {
+	args []string
+	next int
+	// Index of the option read last, which errors point at by default.
+	current int
+	// Set by --option=value until the value is read.
+	inline    string
+	hasInline bool
+	// Set once "--" has been read; after that nothing is an option.
+	literal bool
+}
+
+func newArgScanner(args []string) *argScanner {
+	return &argScanner{args: args}
+}
+
+func isShortOption(arg string) bool {
+	return len(arg) == 2 && arg[0] == '-' && (arg[1] >= 'a' && arg[1] <= 'z' || arg[1] >= 'A' && arg[1] <= 'Z')
+}
+
+func (s *argScanner) isOption(arg string) bool {
+	return !s.literal && (len(arg) > 2 && strings.HasPrefix(arg, "--") || isShortOption(arg))
+}
+
+// skipTerminator steps over "--", switching to literal mode.
+func (s *argScanner) skipTerminator() {
+	if !s.literal && s.next < len(s.args) && s.args[s.next] == "--" {
+		s.literal = true
+		s.next++
+	}
+}
+
+func (s *argScanner) more() bool {
+	s.skipTerminator()
+	return s.next < len(s.args)
+}
+
+// fail attributes err to the current option.
+func (s *argScanner) fail(err error) error {
+	return s.failAt(s.current, err)
+}
+
+func (s *argScanner) failAt(index int, err error) error {
+	var argErr *argumentError
+	if err == nil || errors.As(err, &argErr) {
+		return err
+	}
+	return &argumentError{index, err}
+}
+
+// option reads the next argument, which must be an option. Short aliases are
+// expanded, and a value given as --option=value is kept for value().
+func (s *argScanner) option() (string, error) {
+	s.current = s.next
+	arg := s.args[s.next]
+	s.next++
+	if !s.isOption(arg) {
+		return "", s.fail(errors.New("unexpected argument: '" + arg + "'"))
+	}
+	s.inline, s.hasInline = "", false
+	if strings.HasPrefix(arg, "--") {
+		if separator := strings.Index(arg, "="); separator != -1 {
+			s.inline, s.hasInline = arg[separator+1:], true
+			arg = arg[:separator]
+		}
+	} else if long, ok := shortOptions[arg]; ok {
+		arg = long
+	}
+	return arg, nil
+}
+
+// flag checks that the current option, which doesn't take a value, wasn't
+// given one with --option=value.
+func (s *argScanner) flag(option string) error {
+	if s.hasInline {
+		return s.fail(errors.New(option + " doesn't take a value"))
+	}
+	return nil
+}
+
+// peekValue returns the next argument if it's a value.
+func (s *argScanner) peekValue() (string, bool) {
+	if s.hasInline {
+		return s.inline, true
+	}
+	s.skipTerminator()
+	if s.next >= len(s.args) || s.isOption(s.args[s.next]) {
+		return "", false
+	}
+	return s.args[s.next], true
+}
+
+func (s *argScanner) skipValue() {
+	if s.hasInline {
+		s.inline, s.hasInline = "", false
+		return
+	}
+	s.next++
+}
+
+// value reads the value of option.
+func (s *argScanner) value(option string) (string, error) {
+	value, ok := s.peekValue()
+	if !ok {
+		return "", s.fail(errors.New("missing value for " + option))
+	}
+	s.skipValue()
+	return value, nil
+}
+
+// optionalValue reads the next argument if it's a value.
+func (s *argScanner) optionalValue() (string, bool) {
+	value, ok := s.peekValue()
+	if ok {
+		s.skipValue()
+	}
+	return value, ok
+}
+
+// values reads arguments up to the next option or --end.
+func (s *argScanner) values() []string {
+	values := make([]string, 0)
+	for {
+		value, ok := s.optionalValue()
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+	s.skipEnd()
+	return values
+}
+
+// subOptions reads key:value arguments such as "style:danger" up to the next
+// option or --end. It returns the index of the first one for error messages.
+func (s *argScanner) subOptions() (int, []string) {
+	start := s.next
+	subOptions := make([]string, 0)
+	for {
+		value, ok := s.peekValue()
+		if !ok || !strings.Contains(value, ":") {
+			break
+		}
+		if len(subOptions) == 0 {
+			start = s.next
+		}
+		subOptions = append(subOptions, value)
+		s.skipValue()
+	}
+	s.skipEnd()
+	return start, subOptions
+}
+
+func (s *argScanner) skipEnd() {
+	if !s.literal && s.next < len(s.args) && s.args[s.next] == endOption {
+		s.next++
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Files are attached with
+//
+//	--attach <path> [name:report.txt] [type:text/plain]
+//
+// and read just before the email is sent, so that "mendsail run" can attach
+// a file written by the command. The MIME type is taken from the extension,
+// or sniffed from the content if the extension is unknown.
+
+// Default for the attach-max-bytes setting. Mendsail rejects larger emails.
+// The limit applies to the attachments as sent, i.e. base64-encoded, which is
+// about 4/3 of their size on disk.
+const defaultAttachMaxBytes = 10 * 1024 * 1024
+
+// Value of attach-max-bytes that turns the limit off, stored as -1.
+const attachUnlimited = "unlimited"
+
+// With --attach-compress, text attachments larger than this are gzipped.
+const attachCompressThreshold = 64 * 1024
+
+type sendAttachment struct {
+	path        string
+	name        string
+	contentType string
+	// Set for the inline images of Image blocks, see inlineLocalImages.
+	contentId string
+	data      []byte
+}
+
+func parseAttachMaxBytes(option string, value string) (int, error) {
+	if value == attachUnlimited {
+		return -1, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, errors.New("invalid value for " + option + ": '" + value + "' (should be a positive integer or " + attachUnlimited + ")")
+	}
+	return number, nil
+}
+
+// parseAttach reads the path and sub-options of --attach.
+func parseAttach(scanner *argScanner, arg string) (sendAttachment, error) {
+	path, err := scanner.value(arg)
+	if err != nil {
+		return sendAttachment{}, err
+	}
+	attachment := sendAttachment{path: path}
+	start, subOptions := scanner.subOptions()
+	for k, subOption := range subOptions {
+		key := subOption[:strings.Index(subOption, ":")]
+		value := subOption[len(key)+1:]
+		switch key {
+		case "name":
+			if value == "" || strings.ContainsAny(value, "/\\") {
+				return attachment, scanner.failAt(start+k, errors.New("invalid attachment name: '"+value+"'"))
+			}
+			attachment.name = value
+		case "type":
+			if _, _, err := mime.ParseMediaType(value); err != nil || !strings.Contains(value, "/") {
+				return attachment, scanner.failAt(start+k, errors.New("invalid attachment type: '"+value+"'"))
+			}
+			attachment.contentType = value
+		default:
+			return attachment, scanner.failAt(start+k, errors.New("unknown option: '"+subOption+"'"))
+		}
+	}
+	return attachment, nil
+}
+
+// loadAttachments reads the attached files and local images, fills in their
+// names and types, compresses them if asked to and checks the total size.
+func loadAttachments(options *sendOptions) error {
+	for i := range options.attachments {
+		attachment := &options.attachments[i]
+		data, err := ioutil.ReadFile(attachment.path)
+		if err != nil {
+			return validationError(errors.New("could not read attachment: " + err.Error()))
+		}
+		attachment.data = data
+		if attachment.name == "" {
+			attachment.name = filepath.Base(attachment.path)
+		}
+		if attachment.contentType == "" {
+			attachment.contentType = detectContentType(attachment.name, data)
+		}
+		if options.attachCompress && len(data) > attachCompressThreshold && isTextType(attachment.contentType) {
+			if err := compressAttachment(attachment); err != nil {
+				return err
+			}
+		}
+	}
+	if err := inlineLocalImages(options); err != nil {
+		return err
+	}
+	total := 0
+	for _, attachment := range options.attachments {
+		total += base64.StdEncoding.EncodedLen(len(attachment.data))
+	}
+	if options.attachMaxBytes > 0 && total > options.attachMaxBytes {
+		return validationError(fmt.Errorf("attachments are %d bytes in total when encoded, over the limit of %d bytes (see --attach-max-bytes)", total, options.attachMaxBytes))
+	}
+	return nil
+}
+
+func detectContentType(name string, data []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
+
+var textContentTypes = []string{"application/json", "application/xml", "application/javascript", "application/x-ndjson", "application/yaml"}
+
+func isTextType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || containsString(textContentTypes, mediaType)
+}
+
+func compressAttachment(attachment *sendAttachment) error {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Name = attachment.name
+	if _, err := writer.Write(attachment.data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	attachment.data = buffer.Bytes()
+	attachment.name += ".gz"
+	attachment.contentType = "application/gzip"
+	return nil
+}
+
+func sendAttachmentsToPayload(attachments []sendAttachment) []mendsail.Attachment {
+	if len(attachments) == 0 {
+		return nil
+	}
+	payload := make([]mendsail.Attachment, len(attachments))
+	for i, attachment := range attachments {
+		if attachment.contentId != "" {
+			payload[i] = mendsail.NewInlineAttachment(attachment.name, attachment.contentType, attachment.contentId, attachment.data)
+		} else {
+			payload[i] = mendsail.NewAttachment(attachment.name, attachment.contentType, attachment.data)
+		}
+	}
+	return payload
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseSendArgs_Attach(t *testing.T) {
+	args := []string{
+		"--attach", "out/report.csv", "name:report.csv", "type:text/csv",
+		"--attach", "build.log",
+		"--attach-compress",
+		"--attach-max-bytes", "1024",
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	expected := []sendAttachment{
+		{path: "out/report.csv", name: "report.csv", contentType: "text/csv"},
+		{path: "build.log"},
+	}
+	if !reflect.DeepEqual(actual.attachments, expected) {
+		t.Errorf("sendOptions.attachments: expected=%v actual=%v", expected, actual.attachments)
+	}
+	if !actual.attachCompress {
+		t.Errorf("sendOptions.attachCompress: expected=true actual=false")
+	}
+	if actual.attachMaxBytes != 1024 {
+		t.Errorf("sendOptions.attachMaxBytes: expected=1024 actual=%d", actual.attachMaxBytes)
+	}
+}
+
+func Test_parseSendArgs_AttachErrors(t *testing.T) {
+	_, err := parseSendArgs([]string{"--attach"})
+	expectError(t, "missing value for --attach (argument 1)", err)
+	_, err = parseSendArgs([]string{"--attach", "a.txt", "size:1"})
+	expectError(t, "unknown option: 'size:1' (argument 3)", err)
+	_, err = parseSendArgs([]string{"--attach", "a.txt", "name:../b.txt"})
+	expectError(t, "invalid attachment name: '../b.txt' (argument 3)", err)
+	_, err = parseSendArgs([]string{"--attach", "a.txt", "name:b.txt", "type:text"})
+	expectError(t, "invalid attachment type: 'text' (argument 4)", err)
+	_, err = parseSendArgs([]string{"--attach-max-bytes", "lots"})
+	expectError(t, "invalid value for --attach-max-bytes: 'lots' (should be a positive integer or unlimited) (argument 1)", err)
+	_, err = parseSendArgs([]string{"--attach-max-bytes", "0"})
+	expectError(t, "invalid value for --attach-max-bytes: '0' (should be a positive integer or unlimited) (argument 1)", err)
+}
+
+func Test_applySettings_AttachMaxBytes(t *testing.T) {
+	t.Setenv("MENDSAIL_PROFILE", "")
+	writeConfig(t, "default-profile = \"production\"\n[profiles.production]\nattach-max-bytes = \"unlimited\"\n")
+	options, err := parseSendArgs([]string{})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	if options.attachMaxBytes != -1 {
+		t.Errorf("attachMaxBytes from profile: expected=-1 actual=%d", options.attachMaxBytes)
+	}
+
+	writeConfig(t, "default-profile = \"production\"\n[profiles.production]\nattach-max-bytes = 2048\n")
+	options, err = parseSendArgs([]string{"--attach-max-bytes", "unlimited"})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	if options.attachMaxBytes != -1 {
+		t.Errorf("attachMaxBytes from flag: expected=-1 actual=%d", options.attachMaxBytes)
+	}
+
+	path := writeConfig(t, "default-profile = \"production\"\n[profiles.production]\nattach-max-bytes = 0\n")
+	options, err = parseSendArgs([]string{})
+	expectNoError(t, err)
+	expectError(t, path+":3: profiles.production: 'attach-max-bytes' is invalid: invalid value for --attach-max-bytes: '0' (should be a positive integer or unlimited)", applySettings(options))
+}
+
+func writeAttachment(t *testing.T, dir string, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_loadAttachments(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	options := sendOptions{
+		attachments: []sendAttachment{
+			{path: writeAttachment(t, dir, "graph", png)},
+			{path: writeAttachment(t, dir, "build.json", []byte("{}"))},
+			{path: writeAttachment(t, dir, "notes", []byte("hello")), name: "notes.md", contentType: "text/markdown"},
+		},
+	}
+	expectNoError(t, loadAttachments(&options))
+	expected := []sendAttachment{
+		{path: filepath.Join(dir, "graph"), name: "graph", contentType: "image/png", data: png},
+		{path: filepath.Join(dir, "build.json"), name: "build.json", contentType: "application/json", data: []byte("{}")},
+		{path: filepath.Join(dir, "notes"), name: "notes.md", contentType: "text/markdown", data: []byte("hello")},
+	}
+	if !reflect.DeepEqual(options.attachments, expected) {
+		t.Errorf("sendOptions.attachments: expected=%v actual=%v", expected, options.attachments)
+	}
+}
+
+func Test_loadAttachments_Compress(t *testing.T) {
+	dir := t.TempDir()
+	log := []byte(strings.Repeat("all good\n", attachCompressThreshold))
+	binary := bytes.Repeat([]byte{0}, attachCompressThreshold+1)
+	options := sendOptions{
+		attachCompress: true,
+		attachments: []sendAttachment{
+			{path: writeAttachment(t, dir, "build.log", log), contentType: "text/plain"},
+			{path: writeAttachment(t, dir, "small.txt", []byte("hello")), contentType: "text/plain"},
+			{path: writeAttachment(t, dir, "core", binary)},
+		},
+	}
+	expectNoError(t, loadAttachments(&options))
+
+	compressed := options.attachments[0]
+	exceptStringsEqual(t, "build.log.gz", compressed.name)
+	exceptStringsEqual(t, "application/gzip", compressed.contentType)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed.data))
+	expectNoError(t, err)
+	decompressed, err := ioutil.ReadAll(reader)
+	expectNoError(t, err)
+	if !bytes.Equal(decompressed, log) {
+		t.Errorf("decompressed attachment differs from the file")
+	}
+
+	exceptStringsEqual(t, "small.txt", options.attachments[1].name)
+	exceptStringsEqual(t, "core", options.attachments[2].name)
+	exceptStringsEqual(t, "application/octet-stream", options.attachments[2].contentType)
+}
+
+func Test_loadAttachments_Errors(t *testing.T) {
+	dir := t.TempDir()
+	options := sendOptions{
+		attachMaxBytes: 15,
+		attachments: []sendAttachment{
+			{path: writeAttachment(t, dir, "a.txt", []byte("12345"))},
+			{path: writeAttachment(t, dir, "b.txt", []byte("67890"))},
+		},
+	}
+	err := loadAttachments(&options)
+	expectError(t, "attachments are 16 bytes in total when encoded, over the limit of 15 bytes (see --attach-max-bytes)", err)
+	expectExitCode(t, 3, err)
+	options.attachMaxBytes = 16
+	expectNoError(t, loadAttachments(&options))
+	options.attachMaxBytes = -1
+	expectNoError(t, loadAttachments(&options))
+
+	missing := filepath.Join(dir, "missing.txt")
+	options = sendOptions{attachments: []sendAttachment{{path: missing}}}
+	err = loadAttachments(&options)
+	expectError(t, "could not read attachment: open "+missing+": no such file or directory", err)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Block options are parsed from the specs below: the values that follow the
+// option, then any key:value sub-options. For example
+//
+//	--button <url> <text> [style:...] [ghost:true]
+//
+// is two args followed by two sub-options. A block without args, such as
+// --divider, takes no value at all.
+
+type blockArg struct {
+	// Used in the error for a missing value, e.g. "missing button text". The
+	// first arg is reported as "missing value for <option>" instead.
+	name string
+	// An optional arg is skipped if the next argument is an option.
+	optional bool
+	set      func(block *sendBlock, value string) error
+}
+
+type blockSubOption struct {
+	key string
+	set func(block *sendBlock, value string) error
+}
+
+type blockSpec struct {
+	blockType string
+	args      []blockArg
+	// Reads every value up to the next option or --end instead of args.
+	variadic   func(block *sendBlock, values []string)
+	subOptions []blockSubOption
+	// Checks the block or fills in defaults once everything is read.
+	finish func(block *sendBlock) error
+}
+
+func setText(block *sendBlock, value string) error {
+	block.text = value
+	return nil
+}
+
+func setUrl(block *sendBlock, value string) error {
+	block.url = value
+	return nil
+}
+
+var styleSubOption = blockSubOption{"style", func(block *sendBlock, value string) error {
+	if err := validateStyle(value); err != nil {
+		return err
+	}
+	block.style = value
+	return nil
+}}
+
+var blockSpecs = map[string]blockSpec{
+	"--heading": {
+		blockType: mendsail.BlockTypeHeading,
+		args:      []blockArg{{set: setText}},
+	},
+	"--paragraph": {
+		blockType: mendsail.BlockTypeParagraph,
+		args:      []blockArg{{set: setText}},
+	},
+	"--code-block": {
+		blockType: mendsail.BlockTypeCodeBlock,
+		args:      []blockArg{{set: setText}},
+	},
+	"--list": {
+		blockType: mendsail.BlockTypeList,
+		variadic: func(block *sendBlock, values []string) {
+			block.items = values
+		},
+	},
+	"--image": {
+		blockType: mendsail.BlockTypeImage,
+		args:      []blockArg{{set: setUrl}},
+		subOptions: []blockSubOption{
+			{"alt", func(block *sendBlock, value string) error {
+				block.alt = value
+				return nil
+			}},
+			{"width", func(block *sendBlock, value string) error {
+				width, err := strconv.Atoi(value)
+				if err != nil {
+					return errors.New("could not parse width as an integer")
+				}
+				block.width = width
+				return nil
+			}},
+		},
+	},
+	"--alert": {
+		blockType:  mendsail.BlockTypeAlert,
+		args:       []blockArg{{set: setText}},
+		subOptions: []blockSubOption{styleSubOption},
+	},
+	"--button": {
+		blockType: mendsail.BlockTypeButton,
+		args:      []blockArg{{set: setUrl}, {name: "button text", set: setText}},
+		subOptions: []blockSubOption{
+			styleSubOption,
+			{"ghost", func(block *sendBlock, value string) error {
+				block.ghost = value != "" && value != "false"
+				return nil
+			}},
+		},
+	},
+	"--link": {
+		blockType: mendsail.BlockTypeLink,
+		args:      []blockArg{{set: setUrl}, {optional: true, set: setText}},
+		finish: func(block *sendBlock) error {
+			if block.text == "" {
+				block.text = block.url
+			}
+			return nil
+		},
+	},
+	"--table": {
+		blockType: mendsail.BlockTypeTable,
+		args: []blockArg{{set: func(block *sendBlock, value string) error {
+			headers, err := parseTableRow(value)
+			block.headers = headers
+			return err
+		}}},
+		subOptions: []blockSubOption{
+			{"row", func(block *sendBlock, value string) error {
+				row, err := parseTableRow(value)
+				if err != nil {
+					return err
+				}
+				if err := validateTableRow(block.headers, row); err != nil {
+					return err
+				}
+				block.rows = append(block.rows, row)
+				return nil
+			}},
+		},
+	},
+	"--divider": {
+		blockType: mendsail.BlockTypeDivider,
+	},
+	"--quote": {
+		blockType: mendsail.BlockTypeQuote,
+		args:      []blockArg{{set: setText}},
+		subOptions: []blockSubOption{
+			{"cite", func(block *sendBlock, value string) error {
+				block.cite = value
+				return nil
+			}},
+		},
+	},
+	"--spacer": {
+		blockType: mendsail.BlockTypeSpacer,
+		subOptions: []blockSubOption{
+			{"size", func(block *sendBlock, value string) error {
+				if err := validateSize(value); err != nil {
+					return err
+				}
+				block.size = value
+				return nil
+			}},
+		},
+	},
+	// Placeholders for blocks read from a file, see expandSourceBlocks.
+	"--markdown": {
+		blockType: markdownSourceBlockType,
+		args:      []blockArg{{set: setText}},
+	},
+	"--table-csv": {
+		blockType: tableCsvSourceBlockType,
+		args:      []blockArg{{set: setText}},
+	},
+	"--table-tsv": {
+		blockType: tableTsvSourceBlockType,
+		args:      []blockArg{{set: setText}},
+	},
+	"--kv-json": {
+		blockType: kvJsonSourceBlockType,
+		args:      []blockArg{{set: setText}},
+	},
+}
+
+func validateSize(size string) error {
+	if size != mendsail.SizeSmall && size != mendsail.SizeMedium && size != mendsail.SizeLarge {
+		return errors.New("invalid size: '" + size + "' (should be one of: small, medium, large)")
+	}
+	return nil
+}
+
+// parseBlock reads the values and sub-options of block option arg.
+func parseBlock(scanner *argScanner, arg string, spec blockSpec) (sendBlock, error) {
+	block := sendBlock{blockType: spec.blockType}
+	if spec.variadic != nil {
+		spec.variadic(&block, scanner.values())
+	} else if len(spec.args) == 0 {
+		if err := scanner.flag(arg); err != nil {
+			return block, err
+		}
+	}
+	for i, blockArg := range spec.args {
+		var value string
+		if i == 0 {
+			var err error
+			if value, err = scanner.value(arg); err != nil {
+				return block, err
+			}
+		} else {
+			var ok bool
+			value, ok = scanner.optionalValue()
+			if !ok && blockArg.optional {
+				continue
+			}
+			if !ok {
+				return block, errors.New("missing " + blockArg.name)
+			}
+		}
+		if err := blockArg.set(&block, value); err != nil {
+			return block, err
+		}
+	}
+
+	if len(spec.subOptions) > 0 {
+		start, subOptions := scanner.subOptions()
+		for k, subOption := range subOptions {
+			if err := spec.setSubOption(&block, subOption); err != nil {
+				return block, scanner.failAt(start+k, err)
+			}
+		}
+	}
+
+	if spec.finish != nil {
+		if err := spec.finish(&block); err != nil {
+			return block, err
+		}
+	}
+	return block, nil
+}
+
+func (spec blockSpec) setSubOption(block *sendBlock, subOption string) error {
+	key := subOption[:strings.Index(subOption, ":")]
+	for _, candidate := range spec.subOptions {
+		if candidate.key == key {
+			return candidate.set(block, subOption[len(key)+1:])
+		}
+	}
+	return errors.New("unknown option: '" + subOption + "'")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_parseSendArgs_LayoutBlocks(t *testing.T) {
+	args := []string{
+		"--heading", "Report",
+		"--divider",
+		"--quote", "Ship it", "cite:Release notes",
+		"--spacer", "size:large",
+		"--spacer",
+		"--quote", "No source",
+	}
+	expected := sendOptions{
+		blocks: []sendBlock{
+			{blockType: "Heading", text: "Report"},
+			{blockType: "Divider"},
+			{blockType: "Quote", text: "Ship it", cite: "Release notes"},
+			{blockType: "Spacer", size: "large"},
+			{blockType: "Spacer"},
+			{blockType: "Quote", text: "No source"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_LayoutBlockErrors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--divider=thick"}, "--divider doesn't take a value (argument 1)"},
+		{[]string{"--divider", "thick"}, "unexpected argument: 'thick' (argument 2)"},
+		{[]string{"--spacer", "size:huge"}, "invalid size: 'huge' (should be one of: small, medium, large) (argument 2)"},
+		{[]string{"--quote"}, "missing value for --quote (argument 1)"},
+		{[]string{"--quote", "text", "author:me"}, "unknown option: 'author:me' (argument 3)"},
+	}
+	for _, test := range tests {
+		_, err := parseSendArgs(test.args)
+		expectError(t, test.expected, err)
+	}
+}
+
+// The help text and completion scripts are generated from spec.go, so every
+// block parsed here should be described there with the same sub-options.
+func Test_blockSpecs_MatchOptionSpecs(t *testing.T) {
+	described := make(map[string]optionSpec)
+	for _, option := range blockOptionGroup.options {
+		described[option.name] = option
+	}
+	for name, spec := range blockSpecs {
+		option, ok := described[name]
+		if !ok {
+			t.Errorf("%s: missing from blockOptionGroup", name)
+			continue
+		}
+		if (len(spec.args) == 0 && spec.variadic == nil) != (option.arg == "") {
+			t.Errorf("%s: blockSpecs and blockOptionGroup disagree on whether it takes a value", name)
+		}
+		if len(spec.subOptions) != len(option.subOptions) {
+			t.Errorf("%s: expected %d sub-options in blockOptionGroup, found %d", name, len(spec.subOptions), len(option.subOptions))
+			continue
+		}
+		for i, subOption := range spec.subOptions {
+			exceptStringsEqual(t, subOption.key, option.subOptions[i].key)
+		}
+	}
+}
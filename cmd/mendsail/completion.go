@@ -0,0 +1,198 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// "mendsail completion <shell>" prints a completion script generated from
+// commandSpecs, so that new options are completed without touching the
+// scripts. Profile names are looked up at completion time with
+// "mendsail config profiles".
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+//go:embed completion.bash.tmpl
+var bashCompletionTemplate string
+
+//go:embed completion.zsh.tmpl
+var zshCompletionTemplate string
+
+//go:embed completion.fish.tmpl
+var fishCompletionTemplate string
+
+// The templates only see exported fields, so the spec is copied into these.
+
+type completionOption struct {
+	Name  string
+	Short string
+	Help  string
+	// Empty for options that don't take a value.
+	Arg      string
+	Values   []string
+	Complete string
+	// Commands accepting the option.
+	Commands []string
+}
+
+type completionCommand struct {
+	Name               string
+	Help               string
+	Subcommands        []string
+	SettingSubcommands []string
+	Options            []completionOption
+}
+
+type completionBlock struct {
+	Name string
+	// Sub-options with their values, e.g. "style:danger".
+	Values []string
+	// Sub-options that take free text, e.g. "alt:".
+	Keys []string
+}
+
+type completionData struct {
+	Commands []completionCommand
+	Options  []completionOption
+	Blocks   []completionBlock
+	Settings []string
+	// Option names, short aliases included, for case patterns.
+	ProfileOptions []string
+	FileOptions    []string
+	TextOptions    []string
+}
+
+func newCompletionOption(option optionSpec) completionOption {
+	help := option.help
+	if help == "" {
+		help = option.displayArg()
+	}
+	return completionOption{
+		Name:     option.name,
+		Short:    option.short,
+		Help:     help,
+		Arg:      option.arg,
+		Values:   option.values,
+		Complete: option.complete,
+	}
+}
+
+func (option completionOption) names() []string {
+	if option.Short != "" {
+		return []string{option.Name, option.Short}
+	}
+	return []string{option.Name}
+}
+
+func newCompletionData() completionData {
+	data := completionData{}
+	index := make(map[string]int)
+	for _, command := range commandSpecs {
+		completion := completionCommand{
+			Name:               command.name,
+			Help:               command.help,
+			Subcommands:        command.subcommands,
+			SettingSubcommands: command.settingSubcommands,
+		}
+		for _, option := range command.options() {
+			completion.Options = append(completion.Options, newCompletionOption(option))
+			i, ok := index[option.name]
+			if !ok {
+				i = len(data.Options)
+				index[option.name] = i
+				data.Options = append(data.Options, newCompletionOption(option))
+			}
+			data.Options[i].Commands = append(data.Options[i].Commands, command.name)
+
+			if ok || len(option.subOptions) == 0 {
+				continue
+			}
+			block := completionBlock{Name: option.name}
+			for _, subOption := range option.subOptions {
+				if len(subOption.values) == 0 {
+					block.Keys = append(block.Keys, subOption.key+":")
+				}
+				for _, value := range subOption.values {
+					block.Values = append(block.Values, subOption.key+":"+value)
+				}
+			}
+			data.Blocks = append(data.Blocks, block)
+		}
+		data.Commands = append(data.Commands, completion)
+	}
+
+	for _, option := range data.Options {
+		switch {
+		case option.Arg == "" || len(option.Values) > 0:
+		case option.Complete == completeProfile:
+			data.ProfileOptions = append(data.ProfileOptions, option.names()...)
+		case option.Complete == completeFile:
+			data.FileOptions = append(data.FileOptions, option.names()...)
+		default:
+			data.TextOptions = append(data.TextOptions, option.names()...)
+		}
+	}
+
+	data.Settings = []string{"default-profile"}
+	for _, s := range settings {
+		data.Settings = append(data.Settings, s.key)
+	}
+	return data
+}
+
+var completionFuncs = template.FuncMap{
+	"join": strings.Join,
+	"names": func(options []completionOption) []string {
+		names := make([]string, 0, len(options))
+		for _, option := range options {
+			names = append(names, option.Name)
+		}
+		return names
+	},
+	"commandNames": func(commands []completionCommand) []string {
+		names := make([]string, 0, len(commands))
+		for _, command := range commands {
+			names = append(names, command.Name)
+		}
+		return names
+	},
+	"pattern": func(option completionOption) string {
+		return strings.Join(option.names(), "|")
+	},
+	"trimDashes": func(name string) string {
+		return strings.TrimLeft(name, "-")
+	},
+	// Single-quoted strings for zsh and fish.
+	"zshQuote": func(value string) string {
+		return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	},
+	"fishQuote": func(value string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+	},
+}
+
+var completionTemplates = map[string]*template.Template{
+	"bash": template.Must(template.New("bash").Funcs(completionFuncs).Parse(bashCompletionTemplate)),
+	"zsh":  template.Must(template.New("zsh").Funcs(completionFuncs).Parse(zshCompletionTemplate)),
+	"fish": template.Must(template.New("fish").Funcs(completionFuncs).Parse(fishCompletionTemplate)),
+}
+
+var completionUsage = "usage: mendsail completion " + strings.Join(completionShells, "|")
+
+func writeCompletion(w io.Writer, shell string) error {
+	return completionTemplates[shell].Execute(w, newCompletionData())
+}
+
+func runCompletion(args []string) error {
+	if len(args) != 1 {
+		return usageError(errors.New("missing shell, " + completionUsage))
+	}
+	if _, ok := completionTemplates[args[0]]; !ok {
+		return usageError(errors.New("unknown shell: " + args[0] + ", " + completionUsage))
+	}
+	return writeCompletion(os.Stdout, args[0])
+}
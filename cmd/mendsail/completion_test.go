@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_writeCompletion(t *testing.T) {
+	expected := map[string][]string{
+		"bash": {
+			"complete -F _mendsail mendsail",
+			`_mendsail_reply "send run queue config login logout completion"`,
+			`--output) _mendsail_reply "text json"; return ;;`,
+			`--profile) _mendsail_reply "$(mendsail config profiles 2>/dev/null)"; return ;;`,
+			`--alert) _mendsail_reply "style:success style:warning style:danger style:info" ;;`,
+			`--image) _mendsail_reply "alt: width:" ;;`,
+			`get|set|unset) _mendsail_reply "default-profile api-key base-url`,
+		},
+		"zsh": {
+			"#compdef mendsail",
+			`'send:Send an email'`,
+			`'--tee:Also pass the command'\''s stdout/stderr through'`,
+			`--profile) compadd -- ${(f)"$(mendsail config profiles 2>/dev/null)"}; return ;;`,
+			"compadd -- style:success style:warning style:danger style:info",
+			"compadd -S '' -- alt: width:",
+		},
+		"fish": {
+			"complete -c mendsail -n __fish_use_subcommand -a send -d 'Send an email'",
+			"complete -c mendsail -n '__fish_seen_subcommand_from send run config' -l to -s t -x -d 'Recipient email address (repeatable)'",
+			"-l profile -x -a '(mendsail config profiles 2>/dev/null)'",
+			"-l file -r -F",
+			"-l tee -d 'Also pass the command\\'s stdout/stderr through'",
+			"complete -c mendsail -n '__mendsail_block_option --alert' -a 'style:success style:warning style:danger style:info'",
+		},
+	}
+	for _, shell := range completionShells {
+		var buffer bytes.Buffer
+		expectNoError(t, writeCompletion(&buffer, shell))
+		for _, line := range expected[shell] {
+			if !strings.Contains(buffer.String(), line) {
+				t.Errorf("%s: expected to contain %s", shell, line)
+			}
+		}
+	}
+}
+
+func Test_runCompletion_UsageErrors(t *testing.T) {
+	err := runCompletion([]string{})
+	expectError(t, "missing shell, usage: mendsail completion bash|zsh|fish", err)
+	expectExitCode(t, 2, err)
+	err = runCompletion([]string{"powershell"})
+	expectError(t, "unknown shell: powershell, usage: mendsail completion bash|zsh|fish", err)
+}
@@ -0,0 +1,811 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// The config file holds named profiles, e.g.:
+//
+//   default-profile = "production"
+//
+//   [profiles.production]
+//   api-key = "..."
+//   to = ["oncall@example.com"]
+//   subject-prefix = "[prod] "
+//
+//   [profiles.staging]
+//   api-key = "..."
+//   base-url = "https://staging.api.mendsail.com/v1"
+//
+// Every setting is resolved in the same order: flag > environment variable >
+// message file (--file) > profile > default.
+
+type setting struct {
+	// Name in the config file.
+	key          string
+	flag         string
+	env          string
+	defaultValue string
+	list         bool
+	number       bool
+}
+
+var settings = []setting{
+	{key: "api-key", flag: "--api-key", env: "MENDSAIL_API_KEY"},
+	{key: "base-url", env: "MENDSAIL_BASE_URL", defaultValue: mendsail.DefaultBaseUrl},
+	{key: "to", flag: "--to", env: "MENDSAIL_TO", list: true},
+	{key: "cc", flag: "--cc", env: "MENDSAIL_CC", list: true},
+	{key: "bcc", flag: "--bcc", env: "MENDSAIL_BCC", list: true},
+	{key: "reply-to", flag: "--reply-to", env: "MENDSAIL_REPLY_TO"},
+	{key: "subject", flag: "--subject", env: "MENDSAIL_SUBJECT"},
+	{key: "subject-prefix", env: "MENDSAIL_SUBJECT_PREFIX"},
+	{key: "stdin-as", flag: "--stdin-as", defaultValue: StdinAsCode},
+	{key: "stdin-position", flag: "--stdin-position", defaultValue: StdinPositionEnd},
+	{key: "stdin-head", flag: "--stdin-head", number: true},
+	{key: "stdin-tail", flag: "--stdin-tail", number: true},
+	{key: "stdin-max-bytes", flag: "--stdin-max-bytes", number: true},
+	{key: "attach-max-bytes", flag: "--attach-max-bytes", defaultValue: strconv.Itoa(defaultAttachMaxBytes), number: true},
+}
+
+func findSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+type configFile struct {
+	path           string
+	defaultProfile *docNode
+	profiles       map[string]*configProfile
+}
+
+type configProfile struct {
+	name   string
+	path   string
+	line   int
+	values map[string]*docNode
+}
+
+type resolvedSetting struct {
+	setting
+	value []string
+	// Where the value came from, e.g. "env MENDSAIL_TO", or empty if unset.
+	source string
+}
+
+func configPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "mendsail", "config.toml"), nil
+}
+
+// loadConfig reads the config file at path. A missing file is the same as an
+// empty one.
+func loadConfig(path string) (*configFile, error) {
+	config := &configFile{
+		path:     path,
+		profiles: make(map[string]*configProfile),
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseDocument(path, data)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(node *docNode, format string, args ...interface{}) error {
+		return &docError{file: path, line: node.line, msg: fmt.Sprintf(format, args...)}
+	}
+
+	for _, key := range root.keys {
+		value := root.fields[key]
+		switch key {
+		case "default-profile":
+			if value.kind != docScalar {
+				return nil, fail(value, "'default-profile' should be a single value")
+			}
+			config.defaultProfile = value
+		case "profiles":
+			if value.kind != docMap {
+				return nil, fail(value, "'profiles' should be a table")
+			}
+			for _, name := range value.keys {
+				profile, err := docToConfigProfile(path, name, value.fields[name])
+				if err != nil {
+					return nil, err
+				}
+				config.profiles[name] = profile
+			}
+		default:
+			return nil, fail(value, "unknown key '%s'", key)
+		}
+	}
+	return config, nil
+}
+
+func docToConfigProfile(path string, name string, node *docNode) (*configProfile, error) {
+	fail := func(node *docNode, format string, args ...interface{}) error {
+		return &docError{file: path, line: node.line, msg: fmt.Sprintf(format, args...)}
+	}
+	if node.kind != docMap {
+		return nil, fail(node, "profile '%s' should be a table", name)
+	}
+	profile := &configProfile{name: name, path: path, line: node.line, values: node.fields}
+	for _, key := range node.keys {
+		value := node.fields[key]
+		s, ok := findSetting(key)
+		if !ok {
+			return nil, fail(value, "profiles.%s: unknown setting '%s'", name, key)
+		}
+		values, err := docSettingValue(s, value)
+		if err == nil {
+			err = validateSetting(s, values)
+		}
+		if err != nil {
+			return nil, fail(value, "profiles.%s: '%s' %s", name, key, err)
+		}
+	}
+	return profile, nil
+}
+
+func docSettingValue(s setting, node *docNode) ([]string, error) {
+	if s.list {
+		return docStringList(node)
+	}
+	value, err := docString(node)
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// validateSetting checks values that can't come from flags (those are checked
+// while parsing them).
+func validateSetting(s setting, values []string) error {
+	for _, value := range values {
+		var err error
+		switch {
+		case s.key == "stdin-as":
+			err = validateStdinAs(value)
+		case s.key == "stdin-position":
+			err = validateStdinPosition(value)
+		case s.key == "attach-max-bytes":
+			_, err = parseAttachMaxBytes(s.flag, value)
+		case s.number:
+			_, err = parseNonNegativeInt(s.flag, value)
+		}
+		if err != nil {
+			return errors.New("is invalid: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// selectProfile picks the profile named by --profile, MENDSAIL_PROFILE or
+// default-profile, in that order, and tells where the name came from. No
+// profile is used if none is named.
+func (config *configFile) selectProfile(fromFlag string) (*configProfile, string, error) {
+	name, source := fromFlag, "flag --profile"
+	if name == "" {
+		name, source = os.Getenv("MENDSAIL_PROFILE"), "env MENDSAIL_PROFILE"
+	}
+	if name == "" && config.defaultProfile != nil {
+		name = config.defaultProfile.value
+		source = fmt.Sprintf("default-profile (%s:%d)", config.path, config.defaultProfile.line)
+		if _, ok := config.profiles[name]; !ok {
+			return nil, "", &docError{file: config.path, line: config.defaultProfile.line, msg: "default-profile '" + name + "' is not defined"}
+		}
+	}
+	if name == "" {
+		return nil, "", nil
+	}
+	profile, ok := config.profiles[name]
+	if !ok {
+		return nil, "", errors.New("unknown profile '" + name + "', available profiles: " + config.profileNames())
+	}
+	return profile, source, nil
+}
+
+func (config *configFile) sortedProfileNames() []string {
+	names := make([]string, 0, len(config.profiles))
+	for name := range config.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (config *configFile) profileNames() string {
+	names := config.sortedProfileNames()
+	if len(names) == 0 {
+		return "none (" + config.path + " defines no profiles)"
+	}
+	return strings.Join(names, ", ")
+}
+
+// flagValues returns the settings given on the command line.
+func (options *sendOptions) flagValues() map[string][]string {
+	return map[string][]string{
+		"api-key":          nonEmpty(options.apiKey),
+		"to":               options.to,
+		"cc":               options.cc,
+		"bcc":              options.bcc,
+		"reply-to":         nonEmpty(options.replyTo),
+		"subject":          nonEmpty(options.subject),
+		"stdin-as":         nonEmpty(options.stdinAs),
+		"stdin-position":   nonEmpty(options.stdinPosition),
+		"stdin-head":       nonZero(options.stdinHead),
+		"stdin-tail":       nonZero(options.stdinTail),
+		"stdin-max-bytes":  nonZero(options.stdinMaxBytes),
+		"attach-max-bytes": attachMaxBytesValue(options.attachMaxBytes),
+	}
+}
+
+func nonEmpty(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
+
+func attachMaxBytesValue(value int) []string {
+	if value < 0 {
+		return []string{attachUnlimited}
+	}
+	return nonZero(value)
+}
+
+func nonZero(value int) []string {
+	if value == 0 {
+		return nil
+	}
+	return []string{strconv.Itoa(value)}
+}
+
+func resolveSettings(options *sendOptions, profile *configProfile) []resolvedSetting {
+	flagValues := options.flagValues()
+	resolved := make([]resolvedSetting, 0, len(settings))
+	for _, s := range settings {
+		resolved = append(resolved, resolveSetting(s, flagValues[s.key], options, profile))
+	}
+	return resolved
+}
+
+func resolveSetting(s setting, fromFlag []string, options *sendOptions, profile *configProfile) resolvedSetting {
+	if len(fromFlag) > 0 {
+		return resolvedSetting{s, fromFlag, "flag " + s.flag}
+	}
+	if s.env != "" {
+		var fromEnv []string
+		if s.list {
+			fromEnv = splitList(os.Getenv(s.env))
+		} else {
+			fromEnv = nonEmpty(os.Getenv(s.env))
+		}
+		if len(fromEnv) > 0 {
+			return resolvedSetting{s, fromEnv, "env " + s.env}
+		}
+	}
+	if fromFile := options.fileValues[s.key]; len(fromFile) > 0 {
+		return resolvedSetting{s, fromFile, fmt.Sprintf("file %s:%d", options.file, options.fileLines[s.key])}
+	}
+	if profile != nil {
+		if node, ok := profile.values[s.key]; ok {
+			// Already validated in loadConfig.
+			value, _ := docSettingValue(s, node)
+			return resolvedSetting{s, value, fmt.Sprintf("profile %s (%s:%d)", profile.name, profile.path, node.line)}
+		}
+	}
+	if s.defaultValue != "" {
+		return resolvedSetting{s, []string{s.defaultValue}, "default"}
+	}
+	return resolvedSetting{s, nil, ""}
+}
+
+func (r resolvedSetting) first() string {
+	if len(r.value) == 0 {
+		return ""
+	}
+	return r.value[0]
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// resolve resolves every setting for options. An API key given with
+// --api-key-file or --api-key-stdin is read here, and a key stored with
+// "mendsail login" is used if no other source sets one.
+func (config *configFile) resolve(options *sendOptions) (*configProfile, string, []resolvedSetting, error) {
+	profile, profileSource, err := config.selectProfile(options.profile)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if err := readApiKeyOptions(options, os.Stdin, os.Stderr); err != nil {
+		return nil, "", nil, err
+	}
+	resolved := resolveSettings(options, profile)
+	for i := range resolved {
+		if resolved[i].key == "api-key" && resolved[i].source == "" {
+			apiKey, source, err := lookupCredential(credentialProfile(profile))
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if apiKey != "" {
+				resolved[i].value = []string{apiKey}
+				resolved[i].source = source
+			}
+		}
+	}
+	return profile, profileSource, resolved, nil
+}
+
+// loadSettings loads the config file and resolves every setting for options.
+// The selected profile is stored in options.profile, so that a queued email is
+// sent with the same profile later.
+func loadSettings(options *sendOptions) ([]resolvedSetting, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	profile, _, resolved, err := config.resolve(options)
+	if profile != nil {
+		options.profile = profile.name
+	}
+	return resolved, err
+}
+
+func applySettings(options *sendOptions) error {
+	resolved, err := loadSettings(options)
+	if err != nil {
+		return err
+	}
+	for _, r := range resolved {
+		switch r.key {
+		case "api-key":
+			options.apiKey = r.first()
+		case "base-url":
+			options.baseUrl = r.first()
+		case "to":
+			options.to = r.value
+		case "cc":
+			options.cc = r.value
+		case "bcc":
+			options.bcc = r.value
+		case "reply-to":
+			options.replyTo = r.first()
+		case "subject":
+			options.subject = r.first()
+		case "subject-prefix":
+			options.subjectPrefix = r.first()
+		case "stdin-as":
+			options.stdinAs = r.first()
+		case "stdin-position":
+			options.stdinPosition = r.first()
+		case "stdin-head":
+			options.stdinHead, _ = strconv.Atoi(r.first())
+		case "stdin-tail":
+			options.stdinTail, _ = strconv.Atoi(r.first())
+		case "stdin-max-bytes":
+			options.stdinMaxBytes, _ = strconv.Atoi(r.first())
+		case "attach-max-bytes":
+			options.attachMaxBytes, _ = parseAttachMaxBytes("attach-max-bytes", r.first())
+		}
+	}
+	return nil
+}
+
+//
+// mendsail config
+//
+
+// maskSecret hides all but the last four characters of long secrets.
+func maskSecret(value string) string {
+	if len(value) < 12 {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-4) + value[len(value)-4:]
+}
+
+func displaySetting(s setting, values []string) string {
+	value := strings.Join(values, ",")
+	if s.key == "api-key" {
+		return maskSecret(value)
+	}
+	return value
+}
+
+// tomlSettingValue formats values for the config file.
+func tomlSettingValue(s setting, values []string) string {
+	if s.list {
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = strconv.Quote(value)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	if _, err := strconv.Atoi(values[0]); s.number && err == nil {
+		return values[0]
+	}
+	return strconv.Quote(values[0])
+}
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// editConfigLines replaces the value defined on line (1-based, possibly
+// spanning several lines for arrays) with replacement, or removes it if
+// replacement is empty.
+func editConfigLines(lines []string, line int, replacement string) []string {
+	end := line
+	raw := lines[line-1]
+	if value := strings.TrimSpace(raw[indexOutsideTomlStrings(raw, '=')+1:]); isTomlMultilineString(value) {
+		for !tomlStringComplete(value, line) && end < len(lines) {
+			value += "\n" + lines[end]
+			end++
+		}
+	} else {
+		value := stripTomlComment(raw)
+		for tomlBracketDepth(value) > 0 && end < len(lines) {
+			value += stripTomlComment(lines[end])
+			end++
+		}
+	}
+	edited := append([]string{}, lines[:line-1]...)
+	if replacement != "" {
+		edited = append(edited, replacement)
+	}
+	return append(edited, lines[end:]...)
+}
+
+// insertConfigLine adds line to the end of the table starting on header
+// (1-based), before any trailing blank lines.
+func insertConfigLine(lines []string, header int, line string) []string {
+	end := header
+	for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "[") {
+		end++
+	}
+	for end > header && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	edited := append([]string{}, lines[:end]...)
+	edited = append(edited, line)
+	return append(edited, lines[end:]...)
+}
+
+// updateConfig sets key in profile (or at the top level if profile is empty)
+// to the given TOML value, or removes it if value is empty. The rest of the
+// file, including comments, is kept as it is.
+func updateConfig(path string, profile string, key string, value string) error {
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = []string{}
+	}
+	line := ""
+	if value != "" {
+		line = tomlKey(key) + " = " + value
+	}
+
+	if profile == "" {
+		switch {
+		case config.defaultProfile != nil:
+			lines = editConfigLines(lines, config.defaultProfile.line, line)
+		case line != "":
+			lines = append([]string{line, ""}, lines...)
+		}
+	} else if p, ok := config.profiles[profile]; ok {
+		if node, ok := p.values[key]; ok {
+			lines = editConfigLines(lines, node.line, line)
+		} else if line != "" {
+			lines = insertConfigLine(lines, p.line, line)
+		}
+	} else if line != "" {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "[profiles."+tomlKey(profile)+"]", line)
+	}
+
+	data = []byte(strings.Join(lines, "\n") + "\n")
+	// Make sure the result still loads before replacing the file.
+	if _, err := parseDocument(path, data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// parseConfigArgs takes --profile out of args and returns the rest.
+func parseConfigArgs(args []string) (string, bool, []string, error) {
+	profile := ""
+	showSecret := false
+	rest := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--show-secret" {
+			showSecret = true
+			continue
+		}
+		if strings.HasPrefix(args[i], "--profile=") {
+			profile = strings.TrimPrefix(args[i], "--profile=")
+			continue
+		}
+		if args[i] == "--profile" {
+			if i+1 == len(args) {
+				return "", false, nil, errors.New("missing value for --profile")
+			}
+			profile = args[i+1]
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	return profile, showSecret, rest, nil
+}
+
+// configTarget returns the profile that get/set/unset work on: the selected
+// one, or the one named with --profile even if it doesn't exist yet.
+func configTarget(config *configFile, fromFlag string) (string, error) {
+	if fromFlag != "" {
+		return fromFlag, nil
+	}
+	profile, _, err := config.selectProfile("")
+	if err != nil {
+		return "", err
+	}
+	if profile == nil {
+		return "", errors.New("no profile selected, use --profile <name>")
+	}
+	return profile.name, nil
+}
+
+func configGet(config *configFile, profileName string, key string, showSecret bool) error {
+	value, err := configGetValue(config, profileName, key, showSecret)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// configGetValue returns the value of key in the profile, with the API key
+// masked unless showSecret is set.
+func configGetValue(config *configFile, profileName string, key string, showSecret bool) (string, error) {
+	if key == "default-profile" {
+		if config.defaultProfile == nil {
+			return "", errors.New("default-profile is not set")
+		}
+		return config.defaultProfile.value, nil
+	}
+	s, ok := findSetting(key)
+	if !ok {
+		return "", errors.New("unknown setting: " + key)
+	}
+	name, err := configTarget(config, profileName)
+	if err != nil {
+		return "", err
+	}
+	profile, ok := config.profiles[name]
+	if !ok {
+		return "", errors.New("unknown profile '" + name + "', available profiles: " + config.profileNames())
+	}
+	node, ok := profile.values[key]
+	if !ok {
+		return "", errors.New(key + " is not set in profile '" + name + "'")
+	}
+	values, _ := docSettingValue(s, node)
+	if showSecret {
+		return strings.Join(values, ","), nil
+	}
+	return displaySetting(s, values), nil
+}
+
+func configSet(config *configFile, profileName string, key string, values []string) error {
+	if len(values) == 0 {
+		return errors.New("missing value, usage: mendsail config set <key> <value>")
+	}
+	if key == "default-profile" {
+		if len(values) != 1 {
+			return errors.New("default-profile takes a single value")
+		}
+		return updateConfig(config.path, "", key, strconv.Quote(values[0]))
+	}
+	s, ok := findSetting(key)
+	if !ok {
+		return errors.New("unknown setting: " + key)
+	}
+	if s.list {
+		items := make([]string, 0)
+		for _, value := range values {
+			items = append(items, splitList(value)...)
+		}
+		values = items
+	} else if len(values) != 1 {
+		return errors.New(key + " takes a single value")
+	}
+	if err := validateSetting(s, values); err != nil {
+		return errors.New(key + " " + err.Error())
+	}
+	switch key {
+	case "to", "cc", "bcc", "reply-to":
+		if err := validateAddresses(s.flag, values); err != nil {
+			return err
+		}
+	}
+	name, err := configTarget(config, profileName)
+	if err != nil {
+		return err
+	}
+	return updateConfig(config.path, name, key, tomlSettingValue(s, values))
+}
+
+func configUnset(config *configFile, profileName string, key string) error {
+	if key == "default-profile" {
+		return updateConfig(config.path, "", key, "")
+	}
+	if _, ok := findSetting(key); !ok {
+		return errors.New("unknown setting: " + key)
+	}
+	name, err := configTarget(config, profileName)
+	if err != nil {
+		return err
+	}
+	return updateConfig(config.path, name, key, "")
+}
+
+// configList prints everything in the config file, one key=value per line.
+func configList(config *configFile) {
+	if config.defaultProfile != nil {
+		fmt.Println("default-profile=" + config.defaultProfile.value)
+	}
+	for _, name := range config.sortedProfileNames() {
+		profile := config.profiles[name]
+		for _, s := range settings {
+			node, ok := profile.values[s.key]
+			if !ok {
+				continue
+			}
+			values, _ := docSettingValue(s, node)
+			fmt.Printf("profiles.%s.%s=%s\n", tomlKey(name), s.key, displaySetting(s, values))
+		}
+	}
+}
+
+// configExplain prints every effective setting and where it came from. args
+// are the same options that would be given to send.
+func configExplain(config *configFile, args []string) error {
+	options, err := parseSendArgs(args)
+	if err != nil {
+		return err
+	}
+	if err := applyMessageFile(options); err != nil {
+		return err
+	}
+	profile, profileSource, resolved, err := config.resolve(options)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if profile == nil {
+		fmt.Fprintf(writer, "profile\t(none)\n")
+	} else {
+		fmt.Fprintf(writer, "profile\t%s\t%s\n", profile.name, profileSource)
+	}
+	for _, r := range resolved {
+		if r.source == "" {
+			fmt.Fprintf(writer, "%s\t(not set)\n", r.key)
+			continue
+		}
+		source := r.source
+		if r.key == "api-key" && options.apiKeyFile != "" {
+			source = "flag --api-key-file"
+		} else if r.key == "api-key" && options.apiKeyStdin {
+			source = "flag --api-key-stdin"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", r.key, displaySetting(r.setting, r.value), source)
+	}
+	return writer.Flush()
+}
+
+func runConfig(args []string) error {
+	usage := "usage: mendsail config get|set|unset|list|profiles|path|explain"
+	if len(args) < 1 {
+		return usageError(errors.New("missing subcommand, " + usage))
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if args[0] == "path" {
+		fmt.Println(path)
+		return nil
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if args[0] == "explain" {
+		return configExplain(config, args[1:])
+	}
+
+	profile, showSecret, rest, err := parseConfigArgs(args[1:])
+	if err != nil {
+		return usageError(err)
+	}
+	if showSecret && args[0] != "get" {
+		return usageError(errors.New("--show-secret only works with config get"))
+	}
+	switch args[0] {
+	case "list":
+		configList(config)
+		return nil
+	case "profiles":
+		// One name per line, for shell completion.
+		for _, name := range config.sortedProfileNames() {
+			fmt.Println(name)
+		}
+		return nil
+	case "get", "set", "unset":
+		if len(rest) < 1 {
+			return usageError(errors.New("missing key, usage: mendsail config " + args[0] + " <key>"))
+		}
+		switch args[0] {
+		case "get":
+			return configGet(config, profile, rest[0], showSecret)
+		case "set":
+			return configSet(config, profile, rest[0], rest[1:])
+		default:
+			return configUnset(config, profile, rest[0])
+		}
+	default:
+		return usageError(errors.New("unknown subcommand: " + args[0] + ", " + usage))
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// writeConfig points XDG_CONFIG_HOME at a temporary directory holding a
+// config file with the given content.
+func writeConfig(t *testing.T, content string) string {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	path := filepath.Join(configHome, "mendsail", "config.toml")
+	expectNoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	expectNoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+const testConfig = "default-profile = \"production\"\n" +
+	"\n" +
+	"[profiles.production]\n" +
+	"api-key = \"prod-key\"\n" +
+	"to = [\"oncall@example.com\", \"manager@example.com\"]\n" +
+	"subject-prefix = \"[prod] \"\n" +
+	"stdin-tail = 100\n" +
+	"\n" +
+	"[profiles.staging]\n" +
+	"api-key = \"staging-key\"\n" +
+	"base-url = \"https://staging.example.com/v1\"\n"
+
+func Test_configPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/config")
+	path, err := configPath()
+	expectNoError(t, err)
+	exceptStringsEqual(t, "/tmp/config/mendsail/config.toml", path)
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/foobar")
+	path, err = configPath()
+	expectNoError(t, err)
+	exceptStringsEqual(t, "/home/foobar/.config/mendsail/config.toml", path)
+}
+
+func Test_loadConfig_MissingFile(t *testing.T) {
+	config, err := loadConfig(filepath.Join(t.TempDir(), "config.toml"))
+	expectNoError(t, err)
+	profile, _, err := config.selectProfile("")
+	expectNoError(t, err)
+	if profile != nil {
+		t.Errorf("expected no profile, got %s", profile.name)
+	}
+}
+
+func Test_loadConfig_Errors(t *testing.T) {
+	path := writeConfig(t, "[profiles.production]\nfoobar = 1\n")
+	_, err := loadConfig(path)
+	expectError(t, path+":2: profiles.production: unknown setting 'foobar'", err)
+
+	path = writeConfig(t, "[profiles.production]\nstdin-as = \"foobar\"\n")
+	_, err = loadConfig(path)
+	expectError(t, path+":2: profiles.production: 'stdin-as' is invalid: invalid value for --stdin-as: 'foobar' (should be one of: code, paragraph, list, markdown, none)", err)
+
+	path = writeConfig(t, "[profiles.production]\napi-key = [\"a\", \"b\"]\n")
+	_, err = loadConfig(path)
+	expectError(t, path+":2: profiles.production: 'api-key' should be a single value", err)
+
+	path = writeConfig(t, "profile = \"production\"\n")
+	_, err = loadConfig(path)
+	expectError(t, path+":1: unknown key 'profile'", err)
+}
+
+func Test_selectProfile(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	config, err := loadConfig(path)
+	expectNoError(t, err)
+
+	t.Setenv("MENDSAIL_PROFILE", "")
+	profile, source, err := config.selectProfile("")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "production", profile.name)
+	exceptStringsEqual(t, "default-profile ("+path+":1)", source)
+
+	t.Setenv("MENDSAIL_PROFILE", "staging")
+	profile, source, err = config.selectProfile("")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "staging", profile.name)
+	exceptStringsEqual(t, "env MENDSAIL_PROFILE", source)
+
+	profile, source, err = config.selectProfile("production")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "production", profile.name)
+	exceptStringsEqual(t, "flag --profile", source)
+
+	_, _, err = config.selectProfile("foobar")
+	expectError(t, "unknown profile 'foobar', available profiles: production, staging", err)
+
+	config, err = loadConfig(writeConfig(t, "default-profile = \"foobar\"\n"))
+	expectNoError(t, err)
+	t.Setenv("MENDSAIL_PROFILE", "")
+	_, _, err = config.selectProfile("")
+	expectError(t, config.path+":1: default-profile 'foobar' is not defined", err)
+}
+
+func Test_resolveSettings_Precedence(t *testing.T) {
+	path := writeConfig(t, testConfig)
+	config, err := loadConfig(path)
+	expectNoError(t, err)
+	profile := config.profiles["production"]
+
+	t.Setenv("MENDSAIL_API_KEY", "env-key")
+	t.Setenv("MENDSAIL_TO", "")
+	t.Setenv("MENDSAIL_CC", " foo@example.com, ,bar@example.com ")
+	t.Setenv("MENDSAIL_BASE_URL", "")
+	t.Setenv("MENDSAIL_SUBJECT_PREFIX", "")
+
+	options := &sendOptions{
+		subject:    "from flag",
+		cc:         []string{"flag@example.com"},
+		file:       "message.yaml",
+		fileValues: map[string][]string{"api-key": {"file-key"}, "reply-to": {"file@example.com"}},
+		fileLines:  map[string]int{"api-key": 1, "reply-to": 2},
+	}
+	resolved := resolveSettings(options, profile)
+	expect := func(key string, value []string, source string) {
+		for _, r := range resolved {
+			if r.key != key {
+				continue
+			}
+			if !reflect.DeepEqual(value, r.value) {
+				t.Errorf("%s: expected=%s actual=%s", key, value, r.value)
+			}
+			exceptStringsEqual(t, source, r.source)
+			return
+		}
+		t.Errorf("%s: not resolved", key)
+	}
+	expect("subject", []string{"from flag"}, "flag --subject")
+	expect("cc", []string{"flag@example.com"}, "flag --cc")
+	expect("api-key", []string{"env-key"}, "env MENDSAIL_API_KEY")
+	expect("to", []string{"oncall@example.com", "manager@example.com"}, "profile production ("+path+":5)")
+	expect("stdin-tail", []string{"100"}, "profile production ("+path+":7)")
+	expect("base-url", []string{mendsail.DefaultBaseUrl}, "default")
+	expect("reply-to", []string{"file@example.com"}, "file message.yaml:2")
+
+	resolved = resolveSettings(&sendOptions{}, nil)
+	expect("reply-to", nil, "")
+	expect("cc", []string{"foo@example.com", "bar@example.com"}, "env MENDSAIL_CC")
+}
+
+func Test_applySettings(t *testing.T) {
+	writeConfig(t, testConfig)
+	t.Setenv("MENDSAIL_PROFILE", "")
+	t.Setenv("MENDSAIL_API_KEY", "")
+	t.Setenv("MENDSAIL_TO", "")
+	t.Setenv("MENDSAIL_SUBJECT_PREFIX", "")
+	options, err := parseSendArgs([]string{"--subject", "Backup failed", "--paragraph", "foo"})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	exceptStringsEqual(t, "prod-key", options.apiKey)
+	exceptStringsEqual(t, mendsail.DefaultBaseUrl, options.baseUrl)
+	if options.stdinTail != 100 {
+		t.Errorf("stdinTail: expected=%d actual=%d", 100, options.stdinTail)
+	}
+	payload, err := sendOptionsToJsonPayload(*options)
+	expectNoError(t, err)
+	expected := "{\"to\":[\"oncall@example.com\",\"manager@example.com\"]," +
+		"\"subject\":\"[prod] Backup failed\"," +
+		"\"blocks\":[{\"type\":\"Paragraph\",\"text\":\"foo\"}]}"
+	exceptStringsEqual(t, expected, string(payload))
+
+	options, err = parseSendArgs([]string{"--profile", "staging", "--api-key", "flag-key"})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	exceptStringsEqual(t, "flag-key", options.apiKey)
+	exceptStringsEqual(t, "https://staging.example.com/v1", options.baseUrl)
+}
+
+func Test_maskSecret(t *testing.T) {
+	exceptStringsEqual(t, "*****", maskSecret("short"))
+	exceptStringsEqual(t, "********5678", maskSecret("abcd12345678"))
+}
+
+func Test_updateConfig_KeepsComments(t *testing.T) {
+	path := writeConfig(t, "# my config\n"+testConfig)
+
+	expectNoError(t, updateConfig(path, "production", "to", "[\"a@example.com\"]"))
+	expectNoError(t, updateConfig(path, "production", "cc", "[\"b@example.com\"]"))
+	expectNoError(t, updateConfig(path, "staging", "api-key", ""))
+	expectNoError(t, updateConfig(path, "dev", "api-key", "\"dev-key\""))
+	expectNoError(t, updateConfig(path, "", "default-profile", "\"dev\""))
+
+	data, err := ioutil.ReadFile(path)
+	expectNoError(t, err)
+	expected := "# my config\n" +
+		"default-profile = \"dev\"\n" +
+		"\n" +
+		"[profiles.production]\n" +
+		"api-key = \"prod-key\"\n" +
+		"to = [\"a@example.com\"]\n" +
+		"subject-prefix = \"[prod] \"\n" +
+		"stdin-tail = 100\n" +
+		"cc = [\"b@example.com\"]\n" +
+		"\n" +
+		"[profiles.staging]\n" +
+		"base-url = \"https://staging.example.com/v1\"\n" +
+		"\n" +
+		"[profiles.dev]\n" +
+		"api-key = \"dev-key\"\n"
+	exceptStringsEqual(t, expected, string(data))
+}
+
+func Test_updateConfig_MultiLineArray(t *testing.T) {
+	path := writeConfig(t, "[profiles.production]\nto = [\n  \"a@example.com\",\n]\nsubject = \"foo\"\n")
+	expectNoError(t, updateConfig(path, "production", "to", ""))
+	data, err := ioutil.ReadFile(path)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "[profiles.production]\nsubject = \"foo\"\n", string(data))
+}
+
+func Test_updateConfig_MultiLineString(t *testing.T) {
+	path := writeConfig(t, "[profiles.production]\nsubject = \"\"\"\nfoo = [\n\"\"\"\nto = [\"a@example.com\"]\n")
+	expectNoError(t, updateConfig(path, "production", "subject", "\"bar\""))
+	data, err := ioutil.ReadFile(path)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "[profiles.production]\nsubject = \"bar\"\nto = [\"a@example.com\"]\n", string(data))
+}
+
+func Test_runConfig_SetAndGet(t *testing.T) {
+	path := writeConfig(t, "")
+	expectNoError(t, os.Remove(path))
+	t.Setenv("MENDSAIL_PROFILE", "")
+
+	err := runConfig([]string{"set", "to", "a@example.com"})
+	expectError(t, "no profile selected, use --profile <name>", err)
+	expectNoError(t, runConfig([]string{"set", "--profile", "dev", "to", "a@example.com,b@example.com"}))
+	expectNoError(t, runConfig([]string{"set", "default-profile", "dev"}))
+	expectNoError(t, runConfig([]string{"set", "stdin-tail", "50"}))
+
+	err = runConfig([]string{"set", "stdin-tail", "foo"})
+	expectError(t, "stdin-tail is invalid: invalid value for --stdin-tail: 'foo' (should be a non-negative integer)", err)
+	err = runConfig([]string{"set", "cc", "foobar"})
+	expectError(t, "invalid email address for --cc: 'foobar'", err)
+	err = runConfig([]string{"set", "foobar", "1"})
+	expectError(t, "unknown setting: foobar", err)
+	err = runConfig([]string{"get", "cc"})
+	expectError(t, "cc is not set in profile 'dev'", err)
+
+	data, err := ioutil.ReadFile(path)
+	expectNoError(t, err)
+	expected := "default-profile = \"dev\"\n" +
+		"\n" +
+		"[profiles.dev]\n" +
+		"to = [\"a@example.com\", \"b@example.com\"]\n" +
+		"stdin-tail = 50\n"
+	exceptStringsEqual(t, expected, string(data))
+
+	info, err := os.Stat(path)
+	expectNoError(t, err)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode: expected=%o actual=%o", 0600, info.Mode().Perm())
+	}
+}
+
+func Test_runConfig_UnknownSubcommand(t *testing.T) {
+	writeConfig(t, "")
+	err := runConfig([]string{"foobar"})
+	expectError(t, "unknown subcommand: foobar, usage: mendsail config get|set|unset|list|profiles|path|explain", err)
+	err = runConfig([]string{})
+	expectError(t, "missing subcommand, usage: mendsail config get|set|unset|list|profiles|path|explain", err)
+}
+
+func Test_configGetValue_MasksApiKey(t *testing.T) {
+	path := writeConfig(t, "[profiles.production]\napi-key = \"abcdefgh-1234\"\nto = [\"a@example.com\"]\n")
+	config, err := loadConfig(path)
+	expectNoError(t, err)
+	value, err := configGetValue(config, "production", "api-key", false)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "*********1234", value)
+	value, err = configGetValue(config, "production", "api-key", true)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "abcdefgh-1234", value)
+	value, err = configGetValue(config, "production", "to", false)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "a@example.com", value)
+
+	err = runConfig([]string{"set", "--profile", "production", "--show-secret", "cc", "b@example.com"})
+	expectError(t, "--show-secret only works with config get", err)
+}
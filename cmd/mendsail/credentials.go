@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// API keys stored with "mendsail login" live in the freedesktop Secret
+// Service (through libsecret's secret-tool) or, where that's not available,
+// in a credentials file readable only by the user. Keys are stored per
+// profile; "default" is used when no profile is selected.
+
+const defaultCredentialProfile = "default"
+
+const apiKeyArgvWarning = "warning: --api-key is visible to other users in the process list and is saved in shell history, " +
+	"use \"mendsail login\", --api-key-file, --api-key-stdin or MENDSAIL_API_KEY instead"
+
+type credentialStore interface {
+	// Describes where keys are stored, e.g. for "stored in ...".
+	name() string
+	get(profile string) (string, error)
+	set(profile string, apiKey string) error
+	// Returns false if there was nothing to delete.
+	delete(profile string) (bool, error)
+}
+
+// Replaced in tests.
+var secretToolCommand = "secret-tool"
+
+type secretServiceStore struct{}
+
+func (store secretServiceStore) name() string {
+	return "the Secret Service"
+}
+
+func (store secretServiceStore) available() bool {
+	_, err := exec.LookPath(secretToolCommand)
+	return err == nil
+}
+
+func (store secretServiceStore) run(stdin string, args ...string) (string, error) {
+	cmd := exec.Command(secretToolCommand, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", errors.New(secretToolCommand + ": " + message)
+	}
+	return stdout.String(), nil
+}
+
+func (store secretServiceStore) get(profile string) (string, error) {
+	if !store.available() {
+		return "", nil
+	}
+	// secret-tool exits with 1 and no output when nothing is found, and also
+	// when there's no Secret Service to ask, so both count as "not found".
+	out, err := store.run("", "lookup", "service", "mendsail", "profile", profile)
+	if err != nil {
+		return "", nil
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (store secretServiceStore) set(profile string, apiKey string) error {
+	if !store.available() {
+		return errors.New(secretToolCommand + " not found")
+	}
+	_, err := store.run(apiKey, "store", "--label", "Mendsail API key ("+profile+")", "service", "mendsail", "profile", profile)
+	return err
+}
+
+func (store secretServiceStore) delete(profile string) (bool, error) {
+	existing, err := store.get(profile)
+	if err != nil || existing == "" {
+		return false, err
+	}
+	_, err = store.run("", "clear", "service", "mendsail", "profile", profile)
+	return err == nil, err
+}
+
+// credentialsFileStore keeps keys in a TOML file with the same layout as the
+// config file, e.g. "[profiles.default]" followed by "api-key = ...".
+type credentialsFileStore struct {
+	path string
+}
+
+func credentialsPath() (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "credentials.toml"), nil
+}
+
+func (store credentialsFileStore) name() string {
+	return store.path
+}
+
+func (store credentialsFileStore) get(profile string) (string, error) {
+	info, err := os.Stat(store.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return "", errors.New(store.path + " is accessible by other users, run: chmod 600 " + store.path)
+	}
+	credentials, err := loadConfig(store.path)
+	if err != nil {
+		return "", err
+	}
+	if p, ok := credentials.profiles[profile]; ok {
+		if node, ok := p.values["api-key"]; ok {
+			return node.value, nil
+		}
+	}
+	return "", nil
+}
+
+func (store credentialsFileStore) set(profile string, apiKey string) error {
+	return updateConfig(store.path, profile, "api-key", strconv.Quote(apiKey))
+}
+
+func (store credentialsFileStore) delete(profile string) (bool, error) {
+	existing, err := store.get(profile)
+	if err != nil || existing == "" {
+		return false, err
+	}
+	return true, updateConfig(store.path, profile, "api-key", "")
+}
+
+// credentialStores returns the stores in order of preference.
+func credentialStores() ([]credentialStore, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	return []credentialStore{secretServiceStore{}, credentialsFileStore{path}}, nil
+}
+
+// lookupCredential returns the stored key for profile and where it was found.
+func lookupCredential(profile string) (string, string, error) {
+	stores, err := credentialStores()
+	if err != nil {
+		return "", "", err
+	}
+	for _, store := range stores {
+		apiKey, err := store.get(profile)
+		if err != nil {
+			return "", "", err
+		}
+		if apiKey != "" {
+			return apiKey, "login (" + store.name() + ")", nil
+		}
+	}
+	return "", "", nil
+}
+
+func credentialProfile(profile *configProfile) string {
+	if profile == nil {
+		return defaultCredentialProfile
+	}
+	return profile.name
+}
+
+// readApiKeyOptions resolves --api-key-file and --api-key-stdin into
+// options.apiKey, and warns about keys given as plain arguments.
+func readApiKeyOptions(options *sendOptions, stdin io.Reader, warnings io.Writer) error {
+	given := 0
+	for _, set := range []bool{options.apiKey != "", options.apiKeyFile != "", options.apiKeyStdin} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return errors.New("only one of --api-key, --api-key-file and --api-key-stdin can be used")
+	}
+	switch {
+	case options.apiKey != "":
+		fmt.Fprintln(warnings, apiKeyArgvWarning)
+	case options.apiKeyFile != "":
+		data, err := ioutil.ReadFile(options.apiKeyFile)
+		if err != nil {
+			return err
+		}
+		options.apiKey = strings.TrimSpace(string(data))
+		if options.apiKey == "" {
+			return errors.New("--api-key-file: " + options.apiKeyFile + " is empty")
+		}
+	case options.apiKeyStdin:
+		apiKey, err := readApiKeyLine(stdin)
+		if err != nil {
+			return err
+		}
+		options.apiKey = apiKey
+	}
+	return nil
+}
+
+// readApiKeyLine reads the first line of r one byte at a time, so that the
+// rest of stdin is left for the message body.
+func readApiKeyLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	apiKey := strings.TrimSpace(string(line))
+	if apiKey == "" {
+		return "", errors.New("no API key was given on stdin")
+	}
+	return apiKey, nil
+}
+
+// promptApiKey asks for the key on the terminal without echoing it.
+func promptApiKey() (string, error) {
+	fmt.Fprint(os.Stderr, "API key: ")
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err == nil {
+		defer stty("echo")
+	}
+	apiKey, err := readApiKeyLine(os.Stdin)
+	fmt.Fprintln(os.Stderr)
+	return apiKey, err
+}
+
+type loginOptions struct {
+	profile     string
+	apiKeyFile  string
+	apiKeyStdin bool
+}
+
+func parseLoginArgs(args []string) (*loginOptions, error) {
+	options := loginOptions{}
+	scanner := newArgScanner(args)
+	for scanner.more() {
+		arg, err := scanner.option()
+		if err != nil {
+			return nil, err
+		}
+		switch arg {
+		case "--api-key-stdin":
+			err = scanner.flag(arg)
+			options.apiKeyStdin = true
+		case "--profile":
+			options.profile, err = scanner.value(arg)
+		case "--api-key-file":
+			options.apiKeyFile, err = scanner.value(arg)
+		default:
+			err = scanner.fail(errors.New("unknown option: " + arg + ", usage: mendsail login [--profile <name>] [--api-key-file <path>|--api-key-stdin]"))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &options, nil
+}
+
+// loginProfile returns the profile to store the key for: the one given with
+// --profile (which doesn't need to exist in the config file) or the selected
+// one.
+func loginProfile(fromFlag string) (string, error) {
+	if fromFlag != "" {
+		return fromFlag, nil
+	}
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+	config, err := loadConfig(path)
+	if err != nil {
+		return "", err
+	}
+	profile, _, err := config.selectProfile("")
+	if err != nil {
+		return "", err
+	}
+	return credentialProfile(profile), nil
+}
+
+func runLogin(args []string) error {
+	options, err := parseLoginArgs(args)
+	if err != nil {
+		return usageError(err)
+	}
+	profile, err := loginProfile(options.profile)
+	if err != nil {
+		return err
+	}
+
+	send := &sendOptions{apiKeyFile: options.apiKeyFile, apiKeyStdin: options.apiKeyStdin}
+	if err := readApiKeyOptions(send, os.Stdin, os.Stderr); err != nil {
+		return err
+	}
+	apiKey := send.apiKey
+	if apiKey == "" {
+		if stdinIsPiped() {
+			apiKey, err = readApiKeyLine(os.Stdin)
+		} else {
+			apiKey, err = promptApiKey()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	stores, err := credentialStores()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, store := range stores {
+		if err := store.set(profile, apiKey); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		fmt.Printf("API key for profile '%s' stored in %s.\n", profile, store.name())
+		return nil
+	}
+	return errors.New("could not store the API key:\n" + strings.Join(errs, "\n"))
+}
+
+func runLogout(args []string) error {
+	options, err := parseLoginArgs(args)
+	if err != nil {
+		return usageError(err)
+	}
+	if options.apiKeyFile != "" || options.apiKeyStdin {
+		return usageError(errors.New("usage: mendsail logout [--profile <name>]"))
+	}
+	profile, err := loginProfile(options.profile)
+	if err != nil {
+		return err
+	}
+	stores, err := credentialStores()
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, store := range stores {
+		deleted, err := store.delete(profile)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			removed = true
+			fmt.Printf("API key for profile '%s' removed from %s.\n", profile, store.name())
+		}
+	}
+	if !removed {
+		return errors.New("no stored API key for profile '" + profile + "'")
+	}
+	return nil
+}
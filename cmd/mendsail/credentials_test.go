@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withoutSecretService makes sure a real secret-tool isn't used.
+func withoutSecretService(t *testing.T) {
+	original := secretToolCommand
+	secretToolCommand = filepath.Join(t.TempDir(), "missing-secret-tool")
+	t.Cleanup(func() { secretToolCommand = original })
+}
+
+func Test_credentialsFileStore(t *testing.T) {
+	withoutSecretService(t)
+	writeConfig(t, "")
+	path, err := credentialsPath()
+	expectNoError(t, err)
+	store := credentialsFileStore{path}
+
+	expectNoError(t, store.set("default", "key-123"))
+	expectNoError(t, store.set("staging", "key-456"))
+	apiKey, err := store.get("default")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "key-123", apiKey)
+
+	apiKey, source, err := lookupCredential("staging")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "key-456", apiKey)
+	exceptStringsEqual(t, "login ("+path+")", source)
+
+	deleted, err := store.delete("default")
+	expectNoError(t, err)
+	if !deleted {
+		t.Errorf("expected the key to be deleted")
+	}
+	deleted, err = store.delete("default")
+	expectNoError(t, err)
+	if deleted {
+		t.Errorf("expected nothing to delete")
+	}
+
+	expectNoError(t, os.Chmod(path, 0644))
+	_, err = store.get("staging")
+	expectError(t, path+" is accessible by other users, run: chmod 600 "+path, err)
+}
+
+func Test_secretServiceStore(t *testing.T) {
+	// A stand-in for secret-tool that keeps secrets in files named after the
+	// profile attribute.
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"  store) cat > \"" + dir + "/$7\" ;;\n" +
+		"  lookup) cat \"" + dir + "/$5\" 2>/dev/null || exit 1 ;;\n" +
+		"  clear) rm \"" + dir + "/$5\" ;;\n" +
+		"esac\n"
+	original := secretToolCommand
+	secretToolCommand = filepath.Join(dir, "secret-tool")
+	t.Cleanup(func() { secretToolCommand = original })
+	expectNoError(t, ioutil.WriteFile(secretToolCommand, []byte(script), 0700))
+
+	store := secretServiceStore{}
+	expectNoError(t, store.set("production", "key-789"))
+	apiKey, err := store.get("production")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "key-789", apiKey)
+	deleted, err := store.delete("production")
+	expectNoError(t, err)
+	if !deleted {
+		t.Errorf("expected the key to be deleted")
+	}
+	apiKey, err = store.get("production")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "", apiKey)
+}
+
+func Test_readApiKeyOptions(t *testing.T) {
+	warnings := &bytes.Buffer{}
+	options := &sendOptions{apiKey: "key-123"}
+	expectNoError(t, readApiKeyOptions(options, nil, warnings))
+	exceptStringsEqual(t, apiKeyArgvWarning+"\n", warnings.String())
+
+	path := filepath.Join(t.TempDir(), "api-key")
+	expectNoError(t, ioutil.WriteFile(path, []byte("key-456\n"), 0600))
+	options = &sendOptions{apiKeyFile: path}
+	expectNoError(t, readApiKeyOptions(options, nil, warnings))
+	exceptStringsEqual(t, "key-456", options.apiKey)
+
+	options = &sendOptions{apiKeyStdin: true}
+	expectNoError(t, readApiKeyOptions(options, strings.NewReader("key-789\nrest of stdin\n"), warnings))
+	exceptStringsEqual(t, "key-789", options.apiKey)
+
+	options = &sendOptions{apiKeyStdin: true}
+	err := readApiKeyOptions(options, strings.NewReader(""), warnings)
+	expectError(t, "no API key was given on stdin", err)
+
+	options = &sendOptions{apiKey: "key-123", apiKeyStdin: true}
+	err = readApiKeyOptions(options, nil, warnings)
+	expectError(t, "only one of --api-key, --api-key-file and --api-key-stdin can be used", err)
+}
+
+func Test_readApiKeyOptions_KeyAndBodyOnStdin(t *testing.T) {
+	options, err := parseSendArgs([]string{"--api-key-stdin", "--stdin-as", "code"})
+	expectNoError(t, err)
+	if !options.wantsStdin() {
+		t.Errorf("wantsStdin: expected=true actual=false")
+	}
+	stdin := strings.NewReader("key-789\nlog output\nmore output\n")
+	expectNoError(t, readApiKeyOptions(options, stdin, &bytes.Buffer{}))
+	exceptStringsEqual(t, "key-789", options.apiKey)
+	content, err := readLimitedLines(stdin, stdinLimits{}, nil)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "log output\nmore output\n", content.text())
+}
+
+func Test_applySettings_StoredCredential(t *testing.T) {
+	withoutSecretService(t)
+	writeConfig(t, "")
+	t.Setenv("MENDSAIL_PROFILE", "")
+	t.Setenv("MENDSAIL_API_KEY", "")
+	path, err := credentialsPath()
+	expectNoError(t, err)
+	expectNoError(t, credentialsFileStore{path}.set("default", "stored-key"))
+
+	options, err := parseSendArgs([]string{})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	exceptStringsEqual(t, "stored-key", options.apiKey)
+
+	t.Setenv("MENDSAIL_API_KEY", "env-key")
+	options, err = parseSendArgs([]string{})
+	expectNoError(t, err)
+	expectNoError(t, applySettings(options))
+	exceptStringsEqual(t, "env-key", options.apiKey)
+}
+
+func Test_parseLoginArgs(t *testing.T) {
+	options, err := parseLoginArgs([]string{"--profile", "staging", "--api-key-stdin"})
+	expectNoError(t, err)
+	exceptStringsEqual(t, "staging", options.profile)
+	if !options.apiKeyStdin {
+		t.Errorf("expected apiKeyStdin to be set")
+	}
+	_, err = parseLoginArgs([]string{"--api-key", "foo"})
+	expectError(t, "unknown option: --api-key, usage: mendsail login [--profile <name>] [--api-key-file <path>|--api-key-stdin] (argument 1)", err)
+}
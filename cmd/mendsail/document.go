@@ -0,0 +1,961 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Documents are the parsed form of the YAML, JSON and TOML files mendsail
+// reads (message definitions, variable files, configuration). Every node
+// remembers the line it was defined on so that schema errors can point at the
+// offending line.
+
+const (
+	docScalar = "scalar"
+	docList   = "list"
+	docMap    = "map"
+)
+
+type docNode struct {
+	kind   string
+	value  string
+	items  []*docNode
+	keys   []string
+	fields map[string]*docNode
+	line   int
+}
+
+func newDocMap(line int) *docNode {
+	return &docNode{kind: docMap, fields: make(map[string]*docNode), line: line}
+}
+
+func (node *docNode) set(key string, value *docNode) error {
+	if _, exists := node.fields[key]; exists {
+		return docErrorf(value.line, "duplicate key '%s'", key)
+	}
+	node.keys = append(node.keys, key)
+	node.fields[key] = value
+	return nil
+}
+
+type docError struct {
+	file string
+	line int
+	msg  string
+}
+
+func docErrorf(line int, format string, args ...interface{}) *docError {
+	return &docError{line: line, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *docError) Error() string {
+	if e.line == 0 {
+		return e.file + ": " + e.msg
+	}
+	return e.file + ":" + strconv.Itoa(e.line) + ": " + e.msg
+}
+
+func parseDocument(fileName string, data []byte) (*docNode, error) {
+	var node *docNode
+	var err error
+	trimmed := bytes.TrimSpace(data)
+	if strings.HasSuffix(fileName, ".toml") {
+		node, err = parseTomlDocument(data)
+	} else if strings.HasSuffix(fileName, ".json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		node, err = parseJsonDocument(data)
+	} else {
+		node, err = parseYamlDocument(data)
+	}
+	var parseErr *docError
+	if errors.As(err, &parseErr) {
+		parseErr.file = fileName
+		return nil, parseErr
+	}
+	if err != nil {
+		return nil, &docError{file: fileName, msg: err.Error()}
+	}
+	return node, nil
+}
+
+// unescape decodes the escape sequence at the start of value, which begins
+// with a backslash, and returns its length. escapes maps the character after
+// the backslash to its replacement, and hexEscapes to the number of hex digits
+// that follow it (as in \u00e9).
+func unescape(value string, escapes map[byte]string, hexEscapes map[byte]int, number int) (string, int, error) {
+	if len(value) < 2 {
+		return "", 0, docErrorf(number, "unterminated string")
+	}
+	if replacement, ok := escapes[value[1]]; ok {
+		return replacement, 2, nil
+	}
+	if digits, ok := hexEscapes[value[1]]; ok && len(value) >= 2+digits {
+		code, err := strconv.ParseUint(value[2:2+digits], 16, 32)
+		if err == nil && utf8.ValidRune(rune(code)) {
+			return string(rune(code)), 2 + digits, nil
+		}
+		return "", 0, docErrorf(number, "invalid escape sequence '%s'", value[:2+digits])
+	}
+	_, size := utf8.DecodeRuneInString(value[1:])
+	return "", 0, docErrorf(number, "invalid escape sequence '%s'", value[:1+size])
+}
+
+//
+// JSON
+//
+
+func parseJsonDocument(data []byte) (*docNode, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := parseJsonValue(decoder, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, docErrorf(lineAtOffset(data, decoder.InputOffset()), "unexpected data after JSON value")
+	}
+	return node, nil
+}
+
+// lineAtOffset returns the line of the first token at or after offset.
+func lineAtOffset(data []byte, offset int64) int {
+	i := int(offset)
+	for i < len(data) && strings.ContainsRune(" \t\r\n,:", rune(data[i])) {
+		i++
+	}
+	return bytes.Count(data[:i], []byte{'\n'}) + 1
+}
+
+func parseJsonValue(decoder *json.Decoder, data []byte) (*docNode, error) {
+	line := lineAtOffset(data, decoder.InputOffset())
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, docErrorf(line, "%s", err)
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			node := &docNode{kind: docList, line: line}
+			for decoder.More() {
+				item, err := parseJsonValue(decoder, data)
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			}
+			_, err := decoder.Token()
+			return node, err
+		}
+		node := newDocMap(line)
+		for decoder.More() {
+			keyLine := lineAtOffset(data, decoder.InputOffset())
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, docErrorf(keyLine, "%s", err)
+			}
+			value, err := parseJsonValue(decoder, data)
+			if err != nil {
+				return nil, err
+			}
+			value.line = keyLine
+			if err := node.set(keyToken.(string), value); err != nil {
+				return nil, err
+			}
+		}
+		_, err := decoder.Token()
+		return node, err
+	case string:
+		return &docNode{kind: docScalar, value: t, line: line}, nil
+	case json.Number:
+		return &docNode{kind: docScalar, value: t.String(), line: line}, nil
+	case bool:
+		return &docNode{kind: docScalar, value: strconv.FormatBool(t), line: line}, nil
+	default:
+		return &docNode{kind: docScalar, line: line}, nil
+	}
+}
+
+//
+// YAML
+//
+// Only the subset of YAML needed for configuration-like documents is
+// supported:
+//
+//   - block mappings and sequences, including "- key: value" items
+//   - flow sequences of scalars, such as [a, "b"]
+//   - plain, single-quoted and double-quoted scalars, with the YAML escapes
+//   - literal (|) and folded (>) block scalars, with an optional - or +
+//   - comments, and a "---" on the first line
+//
+// Anything else is rejected with an error saying it's not supported: anchors,
+// aliases and tags, flow mappings, nested flow collections, block scalar
+// indentation indicators, multiple documents and scalars continued on the
+// next line.
+//
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+var yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+type yamlLine struct {
+	number  int
+	indent  int
+	content string
+	raw     string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func parseYamlDocument(data []byte) (*docNode, error) {
+	parser := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.Contains(raw, "\t") && strings.TrimLeft(raw, " ") != strings.TrimLeft(raw, " \t") {
+			return nil, docErrorf(i+1, "tabs are not allowed for indentation")
+		}
+		content := strings.TrimRight(stripYamlComment(raw), " \t")
+		trimmed := strings.TrimLeft(content, " ")
+		if i == 0 && trimmed == "---" {
+			content, trimmed = "", ""
+		}
+		if len(content) == len(trimmed) && (trimmed == "---" || trimmed == "...") {
+			return nil, docErrorf(i+1, "multiple documents are not supported")
+		}
+		parser.lines = append(parser.lines, yamlLine{
+			number:  i + 1,
+			indent:  len(content) - len(trimmed),
+			content: trimmed,
+			raw:     raw,
+		})
+	}
+	parser.skipBlank()
+	if parser.pos == len(parser.lines) {
+		return newDocMap(1), nil
+	}
+	node, err := parser.parseBlock(parser.lines[parser.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	parser.skipBlank()
+	if parser.pos < len(parser.lines) {
+		return nil, docErrorf(parser.lines[parser.pos].number, "unexpected indentation")
+	}
+	return node, nil
+}
+
+func stripYamlComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if i == 0 || strings.ContainsRune(" \t[,:-", rune(line[i-1])) {
+				quote = r
+			}
+		case r == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].content == "" {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseBlock(indent int) (*docNode, error) {
+	if isYamlListItem(p.lines[p.pos]) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (*docNode, error) {
+	node := &docNode{kind: docList, line: p.lines[p.pos].number}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent < indent {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, docErrorf(line.number, "unexpected indentation")
+		}
+		if !isYamlListItem(line) {
+			// A key at the same indentation ends a sequence nested under a
+			// sibling key.
+			return node, nil
+		}
+		rest := strings.TrimLeft(line.content[1:], " ")
+		var item *docNode
+		var err error
+		if rest == "" {
+			p.pos++
+			item, err = p.parseNested(indent, line.number)
+		} else {
+			// Treat the rest of the line as if it started on its own line,
+			// indented past the dash, so "- key: value" opens a mapping.
+			p.lines[p.pos] = yamlLine{
+				number:  line.number,
+				indent:  line.indent + len(line.content) - len(rest),
+				content: rest,
+				raw:     line.raw,
+			}
+			if isYamlListItem(p.lines[p.pos]) || isYamlKey(p.lines[p.pos]) {
+				item, err = p.parseBlock(p.lines[p.pos].indent)
+			} else if rest[0] == '|' || rest[0] == '>' {
+				p.pos++
+				item, err = p.parseBlockScalar(indent, line.number, rest)
+			} else {
+				p.pos++
+				item, err = parseYamlInline(rest, line.number)
+				if err == nil {
+					err = p.checkNoContinuation(indent)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (*docNode, error) {
+	node := newDocMap(p.lines[p.pos].number)
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent < indent {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, docErrorf(line.number, "unexpected indentation")
+		}
+		key, rest, err := splitYamlKey(line)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		var value *docNode
+		switch {
+		case rest == "":
+			value, err = p.parseNested(indent, line.number)
+		case rest[0] == '|' || rest[0] == '>':
+			value, err = p.parseBlockScalar(indent, line.number, rest)
+		default:
+			value, err = parseYamlInline(rest, line.number)
+			if err == nil {
+				err = p.checkNoContinuation(indent)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		value.line = line.number
+		if err := node.set(key, value); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checkNoContinuation reports a value continued on the next, more indented
+// line, which would otherwise be an unexpected indentation.
+func (p *yamlParser) checkNoContinuation(indent int) error {
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent && !isYamlKey(p.lines[p.pos]) && !isYamlListItem(p.lines[p.pos]) {
+		return docErrorf(p.lines[p.pos].number, "values continued on the next line are not supported (use | or >)")
+	}
+	return nil
+}
+
+func splitYamlKey(line yamlLine) (string, string, error) {
+	content := line.content
+	if content[0] == '"' || content[0] == '\'' {
+		end := strings.IndexRune(content[1:], rune(content[0]))
+		if end == -1 {
+			return "", "", docErrorf(line.number, "unterminated quoted key")
+		}
+		key := content[1 : end+1]
+		rest := strings.TrimLeft(content[end+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", docErrorf(line.number, "expected ':' after key")
+		}
+		return key, strings.TrimLeft(rest[1:], " "), nil
+	}
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' && (i == len(content)-1 || content[i+1] == ' ') {
+			return strings.TrimRight(content[:i], " "), strings.TrimLeft(content[i+1:], " "), nil
+		}
+	}
+	return "", "", docErrorf(line.number, "expected 'key: value'")
+}
+
+// parseNested parses the value of a key or list item that continues on the
+// following, more indented lines. A missing value is an empty scalar.
+func (p *yamlParser) parseNested(parentIndent int, number int) (*docNode, error) {
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		next := p.lines[p.pos]
+		// Sequences are allowed at the same indentation as their parent key.
+		if next.indent > parentIndent || (next.indent == parentIndent && isYamlListItem(next)) {
+			return p.parseBlock(next.indent)
+		}
+	}
+	return &docNode{kind: docScalar, line: number}, nil
+}
+
+func isYamlKey(line yamlLine) bool {
+	_, _, err := splitYamlKey(line)
+	return err == nil
+}
+
+func isYamlListItem(line yamlLine) bool {
+	return line.content == "-" || strings.HasPrefix(line.content, "- ")
+}
+
+func (p *yamlParser) parseBlockScalar(parentIndent int, number int, header string) (*docNode, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimLeft(header[1:], " ")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, docErrorf(number, "block scalar header '%s' is not supported (only %c, %c- and %c+ are)", header, header[0], header[0], header[0])
+	}
+
+	lines := make([]string, 0)
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		raw := p.lines[p.pos].raw
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		lineIndent := len(raw) - len(trimmed)
+		if lineIndent <= parentIndent {
+			break
+		}
+		if blockIndent == -1 {
+			blockIndent = lineIndent
+		}
+		if lineIndent < blockIndent {
+			return nil, docErrorf(p.lines[p.pos].number, "inconsistent indentation in block scalar")
+		}
+		lines = append(lines, raw[blockIndent:])
+		p.pos++
+	}
+
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var text string
+	if folded {
+		text = foldYamlLines(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case len(lines) == 0:
+	case chomp == "":
+		text += "\n"
+	case chomp == "+":
+		text += strings.Repeat("\n", trailing+1)
+	}
+	return &docNode{kind: docScalar, value: text, line: number}, nil
+}
+
+func foldYamlLines(lines []string) string {
+	var builder strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			if line == "" || lines[i-1] == "" || strings.HasPrefix(line, " ") {
+				builder.WriteString("\n")
+			} else {
+				builder.WriteString(" ")
+			}
+		}
+		builder.WriteString(line)
+	}
+	return builder.String()
+}
+
+func parseYamlInline(value string, number int) (*docNode, error) {
+	if value[0] == '[' {
+		if !strings.HasSuffix(value, "]") {
+			return nil, docErrorf(number, "unterminated flow sequence")
+		}
+		node := &docNode{kind: docList, line: number}
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		for inner != "" {
+			var item string
+			var err error
+			item, inner, err = nextYamlFlowItem(inner, number)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, &docNode{kind: docScalar, value: item, line: number})
+		}
+		return node, nil
+	}
+	if value[0] == '{' {
+		return nil, docErrorf(number, "flow mappings are not supported")
+	}
+	if value[0] == '&' || value[0] == '*' || value[0] == '!' {
+		return nil, docErrorf(number, "anchors, aliases and tags are not supported")
+	}
+	scalar, rest, err := parseYamlScalar(value, number)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, docErrorf(number, "unexpected characters after quoted value")
+	}
+	return &docNode{kind: docScalar, value: scalar, line: number}, nil
+}
+
+func nextYamlFlowItem(inner string, number int) (string, string, error) {
+	var item string
+	var rest string
+	switch inner[0] {
+	case '"', '\'':
+		var err error
+		item, rest, err = parseYamlScalar(inner, number)
+		if err != nil {
+			return "", "", err
+		}
+	case '[', '{':
+		return "", "", docErrorf(number, "nested flow collections are not supported")
+	case '&', '*', '!':
+		return "", "", docErrorf(number, "anchors, aliases and tags are not supported")
+	default:
+		end := strings.IndexByte(inner, ',')
+		if end == -1 {
+			end = len(inner)
+		}
+		item = strings.TrimSpace(inner[:end])
+		rest = inner[end:]
+	}
+	rest = strings.TrimSpace(rest)
+	if rest != "" {
+		if rest[0] != ',' {
+			return "", "", docErrorf(number, "expected ',' in flow sequence")
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return item, rest, nil
+}
+
+// parseYamlScalar parses a plain or quoted scalar at the start of value and
+// returns the remainder after a quoted scalar.
+func parseYamlScalar(value string, number int) (string, string, error) {
+	switch value[0] {
+	case '\'':
+		var builder strings.Builder
+		for i := 1; i < len(value); i++ {
+			if value[i] == '\'' {
+				if i+1 < len(value) && value[i+1] == '\'' {
+					builder.WriteByte('\'')
+					i++
+					continue
+				}
+				return builder.String(), strings.TrimSpace(value[i+1:]), nil
+			}
+			builder.WriteByte(value[i])
+		}
+		return "", "", docErrorf(number, "unterminated quoted string")
+	case '"':
+		var builder strings.Builder
+		for i := 1; i < len(value); {
+			switch value[i] {
+			case '"':
+				return builder.String(), strings.TrimSpace(value[i+1:]), nil
+			case '\\':
+				if i+1 == len(value) {
+					return "", "", docErrorf(number, "unterminated quoted string")
+				}
+				text, size, err := unescape(value[i:], yamlEscapes, yamlHexEscapes, number)
+				if err != nil {
+					return "", "", err
+				}
+				builder.WriteString(text)
+				i += size
+			default:
+				builder.WriteByte(value[i])
+				i++
+			}
+		}
+		return "", "", docErrorf(number, "unterminated quoted string")
+	}
+	if value == "~" || value == "null" {
+		return "", "", nil
+	}
+	return value, "", nil
+}
+
+//
+// TOML
+//
+// Only what configuration files need:
+//
+//   - tables and dotted keys, such as [profiles."my team"]
+//   - basic strings with the TOML escapes, and literal strings
+//   - multi-line basic and literal strings (""" and '''), as values of keys
+//   - integers, floats and booleans
+//   - arrays, which may span several lines
+//
+// Inline tables, arrays of tables, dates and times are rejected with an error
+// saying they're not supported.
+//
+
+var tomlEscapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': "\"", '\\': "\\",
+}
+
+var tomlHexEscapes = map[byte]int{'u': 4, 'U': 8}
+
+var tomlDateTime = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}|[0-9]{2}:[0-9]{2})`)
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+var tomlNumber = regexp.MustCompile(`^[+-]?[0-9][0-9_]*(\.[0-9_]+)?([eE][+-]?[0-9]+)?$`)
+
+func parseTomlDocument(data []byte) (*docNode, error) {
+	root := newDocMap(1)
+	current := root
+	definedTables := make(map[string]bool)
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(stripTomlComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			return nil, docErrorf(number, "arrays of tables are not supported")
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, docErrorf(number, "invalid table header")
+			}
+			path, err := parseTomlKey(line[1:len(line)-1], number)
+			if err != nil {
+				return nil, err
+			}
+			name := strings.Join(path, ".")
+			if definedTables[name] {
+				return nil, docErrorf(number, "duplicate table '%s'", name)
+			}
+			definedTables[name] = true
+			current, err = tomlTable(root, path, number)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		equals := indexOutsideTomlStrings(line, '=')
+		if equals < 0 {
+			return nil, docErrorf(number, "expected 'key = value'")
+		}
+		path, err := parseTomlKey(line[:equals], number)
+		if err != nil {
+			return nil, err
+		}
+		value := strings.TrimSpace(line[equals+1:])
+		if isTomlMultilineString(value) {
+			// Taken from the raw lines, as the string may contain '#'.
+			raw := lines[i]
+			value = strings.TrimSpace(raw[indexOutsideTomlStrings(raw, '=')+1:])
+			for !tomlStringComplete(value, number) && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+			}
+		} else {
+			// Arrays may continue on the following lines.
+			for tomlBracketDepth(value) > 0 && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(stripTomlComment(lines[i]))
+			}
+		}
+		node, rest, err := parseTomlValue(value, number)
+		if err != nil {
+			return nil, err
+		}
+		rest = strings.TrimSpace(stripTomlComment(rest))
+		if rest != "" {
+			return nil, docErrorf(number, "unexpected '%s' after value", rest)
+		}
+		table, err := tomlTable(current, path[:len(path)-1], number)
+		if err != nil {
+			return nil, err
+		}
+		if err := table.set(path[len(path)-1], node); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// tomlTable returns the table at path under parent, creating it if needed.
+func tomlTable(parent *docNode, path []string, number int) (*docNode, error) {
+	table := parent
+	for _, key := range path {
+		next, exists := table.fields[key]
+		if !exists {
+			next = newDocMap(number)
+			table.set(key, next)
+		} else if next.kind != docMap {
+			return nil, docErrorf(number, "'%s' is already defined as a value", key)
+		}
+		table = next
+	}
+	return table, nil
+}
+
+func stripTomlComment(line string) string {
+	index := indexOutsideTomlStrings(line, '#')
+	if index < 0 {
+		return line
+	}
+	return line[:index]
+}
+
+// indexOutsideTomlStrings is like strings.IndexByte, but ignores quoted text.
+func indexOutsideTomlStrings(line string, char byte) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case quote != 0:
+			if line[i] == quote {
+				quote = 0
+			}
+		case line[i] == '"' || line[i] == '\'':
+			quote = line[i]
+		case line[i] == char:
+			return i
+		}
+	}
+	return -1
+}
+
+func tomlBracketDepth(value string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		switch {
+		case quote == '"' && value[i] == '\\':
+			i++
+		case quote != 0:
+			if value[i] == quote {
+				quote = 0
+			}
+		case value[i] == '"' || value[i] == '\'':
+			quote = value[i]
+		case value[i] == '[':
+			depth++
+		case value[i] == ']':
+			depth--
+		}
+	}
+	return depth
+}
+
+// parseTomlKey parses a possibly dotted key such as profiles."my team".
+func parseTomlKey(key string, number int) ([]string, error) {
+	path := make([]string, 0)
+	rest := strings.TrimSpace(key)
+	for {
+		if rest == "" {
+			return nil, docErrorf(number, "missing key")
+		}
+		var part string
+		if rest[0] == '"' || rest[0] == '\'' {
+			var err error
+			part, rest, err = parseTomlString(rest, number)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			part = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+			if !tomlBareKey.MatchString(part) {
+				return nil, docErrorf(number, "invalid key '%s'", strings.TrimSpace(key))
+			}
+		}
+		path = append(path, part)
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return path, nil
+		}
+		if rest[0] != '.' {
+			return nil, docErrorf(number, "invalid key '%s'", strings.TrimSpace(key))
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// parseTomlValue parses the value at the start of value and returns the
+// remainder.
+func parseTomlValue(value string, number int) (*docNode, string, error) {
+	if value == "" {
+		return nil, "", docErrorf(number, "missing value")
+	}
+	switch value[0] {
+	case '"', '\'':
+		text, rest, err := parseTomlString(value, number)
+		if err != nil {
+			return nil, "", err
+		}
+		return &docNode{kind: docScalar, value: text, line: number}, rest, nil
+	case '[':
+		node := &docNode{kind: docList, line: number}
+		rest := strings.TrimSpace(value[1:])
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return node, strings.TrimSpace(rest[1:]), nil
+			}
+			if isTomlMultilineString(rest) {
+				return nil, "", docErrorf(number, "multi-line strings are not supported in arrays")
+			}
+			item, itemRest, err := parseTomlValue(rest, number)
+			if err != nil {
+				return nil, "", err
+			}
+			node.items = append(node.items, item)
+			rest = strings.TrimSpace(itemRest)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", docErrorf(number, "unterminated array")
+			}
+		}
+	case '{':
+		return nil, "", docErrorf(number, "inline tables are not supported")
+	}
+	end := strings.IndexAny(value, " \t,]")
+	if end < 0 {
+		end = len(value)
+	}
+	scalar := value[:end]
+	if tomlDateTime.MatchString(scalar) {
+		return nil, "", docErrorf(number, "dates and times are not supported (quote the value)")
+	}
+	if scalar != "true" && scalar != "false" && !tomlNumber.MatchString(scalar) {
+		return nil, "", docErrorf(number, "invalid value '%s' (strings must be quoted)", scalar)
+	}
+	return &docNode{kind: docScalar, value: strings.ReplaceAll(scalar, "_", ""), line: number}, strings.TrimSpace(value[end:]), nil
+}
+
+func isTomlMultilineString(value string) bool {
+	return strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''")
+}
+
+// tomlStringComplete tells whether value holds the whole string it starts
+// with, so that a multi-line string can be read up to its end.
+func tomlStringComplete(value string, number int) bool {
+	_, _, err := parseTomlString(value, number)
+	var parseErr *docError
+	return !errors.As(err, &parseErr) || parseErr.msg != "unterminated string"
+}
+
+// parseTomlString parses the basic, literal or multi-line string at the start
+// of value and returns the remainder.
+func parseTomlString(value string, number int) (string, string, error) {
+	if strings.HasPrefix(value, "'''") {
+		end := strings.Index(value[3:], "'''")
+		if end < 0 {
+			return "", "", docErrorf(number, "unterminated string")
+		}
+		end += 3 + tomlExtraQuotes(value[end+6:], '\'')
+		return trimTomlFirstNewline(value[3:end]), value[end+3:], nil
+	}
+	if value[0] == '\'' {
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", "", docErrorf(number, "unterminated string")
+		}
+		return value[1 : end+1], value[end+2:], nil
+	}
+
+	multiline := strings.HasPrefix(value, `"""`)
+	i := 1
+	if multiline {
+		i = 3 + len(value[3:]) - len(trimTomlFirstNewline(value[3:]))
+	}
+	var builder strings.Builder
+	for i < len(value) {
+		switch {
+		case multiline && strings.HasPrefix(value[i:], `"""`):
+			extra := tomlExtraQuotes(value[i+3:], '"')
+			builder.WriteString(strings.Repeat(`"`, extra))
+			return builder.String(), value[i+3+extra:], nil
+		case !multiline && value[i] == '"':
+			return builder.String(), value[i+1:], nil
+		case !multiline && value[i] == '\n':
+			return "", "", docErrorf(number, "unterminated string")
+		case multiline && value[i] == '\\' && isTomlLineEndingBackslash(value[i+1:]):
+			// A backslash at the end of a line joins it with the next
+			// non-blank text.
+			i = len(value) - len(strings.TrimLeft(value[i+1:], " \t\n"))
+		case value[i] == '\\':
+			text, size, err := unescape(value[i:], tomlEscapes, tomlHexEscapes, number)
+			if err != nil {
+				return "", "", err
+			}
+			builder.WriteString(text)
+			i += size
+		default:
+			builder.WriteByte(value[i])
+			i++
+		}
+	}
+	return "", "", docErrorf(number, "unterminated string")
+}
+
+func isTomlLineEndingBackslash(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t")
+	return rest == "" || rest[0] == '\n'
+}
+
+// tomlExtraQuotes counts the quotes (up to two) right after the closing
+// delimiter of a multi-line string, which belong to the string.
+func tomlExtraQuotes(rest string, quote byte) int {
+	extra := 0
+	for extra < 2 && extra < len(rest) && rest[extra] == quote {
+		extra++
+	}
+	return extra
+}
+
+// trimTomlFirstNewline drops a newline right after the opening delimiter of a
+// multi-line string.
+func trimTomlFirstNewline(value string) string {
+	return strings.TrimPrefix(value, "\n")
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func expectDocMap(t *testing.T, node *docNode, keys []string) {
+	if node.kind != docMap {
+		t.Fatalf("kind: expected=%s actual=%s", docMap, node.kind)
+	}
+	if !reflect.DeepEqual(keys, node.keys) {
+		t.Fatalf("keys: expected=%s actual=%s", keys, node.keys)
+	}
+}
+
+func expectDocScalar(t *testing.T, expected string, expectedLine int, node *docNode) {
+	if node.kind != docScalar {
+		t.Fatalf("kind: expected=%s actual=%s", docScalar, node.kind)
+	}
+	exceptStringsEqual(t, expected, node.value)
+	if node.line != expectedLine {
+		t.Errorf("line: expected=%d actual=%d", expectedLine, node.line)
+	}
+}
+
+func Test_parseDocument_Yaml(t *testing.T) {
+	data := "# comment\n" +
+		"subject: \"Backup # 12\" # trailing comment\n" +
+		"to: [foo@example.com, 'bar@example.com']\n" +
+		"blocks:\n" +
+		"  - heading: Backup failed\n" +
+		"  - alert: it's broken\n" +
+		"    style: danger\n" +
+		"\n" +
+		"  - list:\n" +
+		"    - item 1\n" +
+		"    - item 2\n" +
+		"  - code-block: |\n" +
+		"      line 1\n" +
+		"        line 2\n" +
+		"  - paragraph: >-\n" +
+		"      folded\n" +
+		"      text\n" +
+		"empty:\n"
+	root, err := parseDocument("message.yaml", []byte(data))
+	expectNoError(t, err)
+	expectDocMap(t, root, []string{"subject", "to", "blocks", "empty"})
+	expectDocScalar(t, "Backup # 12", 2, root.fields["subject"])
+	to := root.fields["to"]
+	expectDocScalar(t, "foo@example.com", 3, to.items[0])
+	expectDocScalar(t, "bar@example.com", 3, to.items[1])
+
+	blocks := root.fields["blocks"]
+	if len(blocks.items) != 5 {
+		t.Fatalf("len(blocks): expected=%d actual=%d", 5, len(blocks.items))
+	}
+	expectDocScalar(t, "Backup failed", 5, blocks.items[0].fields["heading"])
+	expectDocMap(t, blocks.items[1], []string{"alert", "style"})
+	expectDocScalar(t, "it's broken", 6, blocks.items[1].fields["alert"])
+	expectDocScalar(t, "danger", 7, blocks.items[1].fields["style"])
+	list := blocks.items[2].fields["list"]
+	expectDocScalar(t, "item 1", 10, list.items[0])
+	expectDocScalar(t, "item 2", 11, list.items[1])
+	expectDocScalar(t, "line 1\n  line 2\n", 12, blocks.items[3].fields["code-block"])
+	expectDocScalar(t, "folded text", 15, blocks.items[4].fields["paragraph"])
+	expectDocScalar(t, "", 18, root.fields["empty"])
+}
+
+func Test_parseDocument_YamlCompactSequence(t *testing.T) {
+	data := "to:\n" +
+		"- foo@example.com\n" +
+		"- bar@example.com\n" +
+		"subject: foobar\n"
+	root, err := parseDocument("message.yml", []byte(data))
+	expectNoError(t, err)
+	expectDocMap(t, root, []string{"to", "subject"})
+	expectDocScalar(t, "bar@example.com", 3, root.fields["to"].items[1])
+	expectDocScalar(t, "foobar", 4, root.fields["subject"])
+}
+
+func Test_parseDocument_YamlErrors(t *testing.T) {
+	_, err := parseDocument("message.yaml", []byte("subject: foo\n  to: bar\n"))
+	expectError(t, "message.yaml:2: unexpected indentation", err)
+	_, err = parseDocument("message.yaml", []byte("subject: foo\nsubject: bar\n"))
+	expectError(t, "message.yaml:2: duplicate key 'subject'", err)
+	_, err = parseDocument("message.yaml", []byte("subject: \"foo\n"))
+	expectError(t, "message.yaml:1: unterminated quoted string", err)
+	_, err = parseDocument("message.yaml", []byte("subject\n"))
+	expectError(t, "message.yaml:1: expected 'key: value'", err)
+	_, err = parseDocument("message.yaml", []byte("subject: \"\\q\"\n"))
+	expectError(t, "message.yaml:1: invalid escape sequence '\\q'", err)
+}
+
+func Test_parseDocument_YamlUnsupported(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"base: &base\n  to: a@example.com\n", "message.yaml:1: anchors, aliases and tags are not supported"},
+		{"<<: *base\n", "message.yaml:1: anchors, aliases and tags are not supported"},
+		{"to: !!str a@example.com\n", "message.yaml:1: anchors, aliases and tags are not supported"},
+		{"to: [a, *b]\n", "message.yaml:1: anchors, aliases and tags are not supported"},
+		{"block: {alert: Hi}\n", "message.yaml:1: flow mappings are not supported"},
+		{"to: [a, [b, c]]\n", "message.yaml:1: nested flow collections are not supported"},
+		{"to: [a, {b: c}]\n", "message.yaml:1: nested flow collections are not supported"},
+		{"text: |2\n    indented\n", "message.yaml:1: block scalar header '|2' is not supported (only |, |- and |+ are)"},
+		{"subject: a\n---\nsubject: b\n", "message.yaml:2: multiple documents are not supported"},
+		{"subject: Backup failed\n  on host-1\n", "message.yaml:2: values continued on the next line are not supported (use | or >)"},
+		{"to:\n  - a@example.com\n    b@example.com\n", "message.yaml:3: values continued on the next line are not supported (use | or >)"},
+	}
+	for _, test := range tests {
+		_, err := parseDocument("message.yaml", []byte(test.data))
+		expectError(t, test.expected, err)
+	}
+}
+
+func Test_parseDocument_YamlScalars(t *testing.T) {
+	data := "escaped: \"tab\\there \\u00e9 \\x41 \\/ \\e[0m\"\n" +
+		"items:\n" +
+		"  - |\n" +
+		"    line 1\n" +
+		"    line 2\n" +
+		"  - >-\n" +
+		"    folded\n" +
+		"    text\n" +
+		"  - plain\n"
+	root, err := parseDocument("message.yaml", []byte(data))
+	expectNoError(t, err)
+	expectDocScalar(t, "tab\there é A / \x1b[0m", 1, root.fields["escaped"])
+	items := root.fields["items"]
+	if len(items.items) != 3 {
+		t.Fatalf("len(items): expected=%d actual=%d", 3, len(items.items))
+	}
+	expectDocScalar(t, "line 1\nline 2\n", 3, items.items[0])
+	expectDocScalar(t, "folded text", 6, items.items[1])
+	expectDocScalar(t, "plain", 9, items.items[2])
+}
+
+func Test_parseDocument_Json(t *testing.T) {
+	data := "{\n" +
+		"  \"subject\": \"foobar\",\n" +
+		"  \"blocks\": [\n" +
+		"    {\"heading\": \"heading 1\"},\n" +
+		"    {\n" +
+		"      \"image\": \"image.png\",\n" +
+		"      \"width\": 123\n" +
+		"    }\n" +
+		"  ]\n" +
+		"}\n"
+	root, err := parseDocument("message.json", []byte(data))
+	expectNoError(t, err)
+	expectDocMap(t, root, []string{"subject", "blocks"})
+	expectDocScalar(t, "foobar", 2, root.fields["subject"])
+	blocks := root.fields["blocks"]
+	expectDocScalar(t, "heading 1", 4, blocks.items[0].fields["heading"])
+	expectDocScalar(t, "image.png", 6, blocks.items[1].fields["image"])
+	expectDocScalar(t, "123", 7, blocks.items[1].fields["width"])
+}
+
+func Test_parseDocument_JsonErrors(t *testing.T) {
+	_, err := parseDocument("message.json", []byte("{\n\"subject\": \"a\",\n\"subject\": \"b\"\n}"))
+	expectError(t, "message.json:3: duplicate key 'subject'", err)
+	_, err = parseDocument("message.json", []byte("{} {}"))
+	expectError(t, "message.json:1: unexpected data after JSON value", err)
+}
+
+func Test_parseDocument_Toml(t *testing.T) {
+	data := "# comment\n" +
+		"default-profile = \"production\" # trailing comment\n" +
+		"\n" +
+		"[profiles.production]\n" +
+		"api-key = 'key # 1'\n" +
+		"to = [\n" +
+		"  \"foo@example.com\", # first\n" +
+		"  \"bar@example.com\",\n" +
+		"]\n" +
+		"stdin-tail = 1_000\n" +
+		"\n" +
+		"[profiles.\"my staging\"]\n" +
+		"retry = true\n"
+	root, err := parseDocument("config.toml", []byte(data))
+	expectNoError(t, err)
+	expectDocMap(t, root, []string{"default-profile", "profiles"})
+	expectDocScalar(t, "production", 2, root.fields["default-profile"])
+	profiles := root.fields["profiles"]
+	expectDocMap(t, profiles, []string{"production", "my staging"})
+	production := profiles.fields["production"]
+	expectDocMap(t, production, []string{"api-key", "to", "stdin-tail"})
+	expectDocScalar(t, "key # 1", 5, production.fields["api-key"])
+	to := production.fields["to"]
+	if len(to.items) != 2 {
+		t.Fatalf("len(to): expected=%d actual=%d", 2, len(to.items))
+	}
+	expectDocScalar(t, "bar@example.com", 6, to.items[1])
+	expectDocScalar(t, "1000", 10, production.fields["stdin-tail"])
+	expectDocScalar(t, "true", 13, profiles.fields["my staging"].fields["retry"])
+}
+
+func Test_parseDocument_TomlErrors(t *testing.T) {
+	_, err := parseDocument("config.toml", []byte("a = 1\na = 2\n"))
+	expectError(t, "config.toml:2: duplicate key 'a'", err)
+	_, err = parseDocument("config.toml", []byte("[a]\n[a]\n"))
+	expectError(t, "config.toml:2: duplicate table 'a'", err)
+	_, err = parseDocument("config.toml", []byte("a = foo\n"))
+	expectError(t, "config.toml:1: invalid value 'foo' (strings must be quoted)", err)
+	_, err = parseDocument("config.toml", []byte("a = 1\n[a.b]\n"))
+	expectError(t, "config.toml:2: 'a' is already defined as a value", err)
+	_, err = parseDocument("config.toml", []byte("a = \"foo\n"))
+	expectError(t, "config.toml:1: unterminated string", err)
+	_, err = parseDocument("config.toml", []byte("a = [1, 2\n"))
+	expectError(t, "config.toml:1: unterminated array", err)
+	_, err = parseDocument("config.toml", []byte("a\n"))
+	expectError(t, "config.toml:1: expected 'key = value'", err)
+	_, err = parseDocument("config.toml", []byte("a = \"\\x41\"\n"))
+	expectError(t, "config.toml:1: invalid escape sequence '\\x'", err)
+	_, err = parseDocument("config.toml", []byte("a = \"\\uD800\"\n"))
+	expectError(t, "config.toml:1: invalid escape sequence '\\uD800'", err)
+	_, err = parseDocument("config.toml", []byte("a = \"\"\"\nfoo\n"))
+	expectError(t, "config.toml:1: unterminated string", err)
+	_, err = parseDocument("config.toml", []byte("a = {b = 1}\n"))
+	expectError(t, "config.toml:1: inline tables are not supported", err)
+	_, err = parseDocument("config.toml", []byte("[[a]]\n"))
+	expectError(t, "config.toml:1: arrays of tables are not supported", err)
+	_, err = parseDocument("config.toml", []byte("a = 2024-01-31\n"))
+	expectError(t, "config.toml:1: dates and times are not supported (quote the value)", err)
+	_, err = parseDocument("config.toml", []byte("a = [\"\"\"b\"\"\"]\n"))
+	expectError(t, "config.toml:1: multi-line strings are not supported in arrays", err)
+}
+
+func Test_parseDocument_TomlStrings(t *testing.T) {
+	data := "basic = \"tab\\there \\\"quoted\\\" \\u00e9 \\U0001F600 C:\\\\dir\"\n" +
+		"literal = 'C:\\dir\\n # not a comment'\n" +
+		"multi = \"\"\"\n" +
+		"line 1 # not a comment\n" +
+		"\n" +
+		"line \\\n" +
+		"    3\\tend\"\"\"\"  # comment\n" +
+		"raw = '''\n" +
+		"C:\\dir\\n\n" +
+		"it's \"quoted\"''''\n" +
+		"after = 1\n"
+	root, err := parseDocument("config.toml", []byte(data))
+	expectNoError(t, err)
+	expectDocMap(t, root, []string{"basic", "literal", "multi", "raw", "after"})
+	expectDocScalar(t, "tab\there \"quoted\" é 😀 C:\\dir", 1, root.fields["basic"])
+	expectDocScalar(t, "C:\\dir\\n # not a comment", 2, root.fields["literal"])
+	expectDocScalar(t, "line 1 # not a comment\n\nline 3\tend\"", 3, root.fields["multi"])
+	expectDocScalar(t, "C:\\dir\\n\nit's \"quoted\"'", 8, root.fields["raw"])
+	expectDocScalar(t, "1", 11, root.fields["after"])
+}
+
+// checkDocNode checks what the rest of mendsail relies on: every node has a
+// known kind and a line inside the document, and maps have a field per key.
+func checkDocNode(t *testing.T, node *docNode, lines int) {
+	if node.line < 1 || node.line > lines {
+		t.Fatalf("line: expected 1-%d actual=%d", lines, node.line)
+	}
+	switch node.kind {
+	case docScalar:
+	case docList:
+		for _, item := range node.items {
+			checkDocNode(t, item, lines)
+		}
+	case docMap:
+		if len(node.keys) != len(node.fields) {
+			t.Fatalf("keys: %d keys for %d fields", len(node.keys), len(node.fields))
+		}
+		for _, key := range node.keys {
+			checkDocNode(t, node.fields[key], lines)
+		}
+	default:
+		t.Fatalf("kind: unknown kind %q", node.kind)
+	}
+}
+
+func FuzzParseDocument(f *testing.F) {
+	seeds := []string{
+		"subject: \"Backup # 12\" # comment\nto: [foo@example.com, 'bar']\nblocks:\n  - heading: a\n  - list:\n    - b\n  - code-block: |\n      c\n  - paragraph: >-\n      d\n      e\n",
+		"- a\n- b: 'c''d'\n  e: \"\\u00e9\\t\"\n",
+		"{\"to\": [\"a@example.com\"], \"blocks\": [{\"paragraph\": \"b\"}], \"n\": 1.5}",
+		"default-profile = \"production\"\n[profiles.production]\nto = [\n  \"a\", # c\n]\nstdin-tail = 1_000\n",
+		"[profiles.\"my staging\"]\nsubject = \"\"\"\nline \\\n  more\"\"\"\nkey = '''raw\\n'''\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lines := bytes.Count(data, []byte("\n")) + 1
+		for _, name := range []string{"message.yaml", "message.json", "config.toml"} {
+			node, err := parseDocument(name, data)
+			if err != nil {
+				var docErr *docError
+				if !errors.As(err, &docErr) || docErr.file != name {
+					t.Fatalf("%s: expected a docError, actual=%v", name, err)
+				}
+				continue
+			}
+			checkDocNode(t, node, lines)
+		}
+	})
+}
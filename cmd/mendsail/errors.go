@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Every failure belongs to one of these kinds, and each kind has its own exit
+// status so that wrappers can tell "fix the command" from "try again later".
+
+const (
+	ErrorKindUsage       = "usage"
+	ErrorKindValidation  = "validation"
+	ErrorKindAuth        = "auth"
+	ErrorKindRateLimited = "rate_limited"
+	ErrorKindServer      = "server"
+	ErrorKindNetwork     = "network"
+	ErrorKindTimeout     = "timeout"
+	ErrorKindOther       = "other"
+)
+
+var errorKindExitCodes = map[string]int{
+	ErrorKindOther:       1,
+	ErrorKindUsage:       2,
+	ErrorKindValidation:  3,
+	ErrorKindAuth:        4,
+	ErrorKindRateLimited: 5,
+	ErrorKindServer:      6,
+	ErrorKindNetwork:     7,
+	ErrorKindTimeout:     8,
+}
+
+// kindError marks an error with its kind without changing its message.
+type kindError struct {
+	kind string
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func usageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{ErrorKindUsage, err}
+}
+
+func validationError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{ErrorKindValidation, err}
+}
+
+func apiErrorKind(e *mendsail.ApiError) string {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout:
+		return ErrorKindTimeout
+	case e.StatusCode >= 500:
+		return ErrorKindServer
+	default:
+		// The API rejected the email itself, e.g. an unverified recipient.
+		return ErrorKindValidation
+	}
+}
+
+func errorKind(err error) string {
+	var kindErr *kindError
+	var apiErr *mendsail.ApiError
+	var netErr *mendsail.NetworkError
+	var docErr *docError
+	switch {
+	case errors.As(err, &kindErr):
+		return kindErr.kind
+	case errors.As(err, &apiErr):
+		return apiErrorKind(apiErr)
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorKindTimeout
+		}
+		return ErrorKindNetwork
+	case errors.As(err, &docErr):
+		return ErrorKindValidation
+	}
+	return ErrorKindOther
+}
+
+// isTemporary tells whether err may go away if the same request is sent again
+// later: network errors, timeouts, rate limiting and server errors.
+func isTemporary(err error) bool {
+	switch errorKind(err) {
+	case ErrorKindNetwork, ErrorKindTimeout, ErrorKindRateLimited, ErrorKindServer:
+		return true
+	}
+	return false
+}
+
+// exitCodeFor returns the status mendsail exits with for err.
+func exitCodeFor(err error) int {
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return errorKindExitCodes[errorKind(err)]
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func expectExitCode(t *testing.T, expected int, err error) {
+	t.Helper()
+	if actual := exitCodeFor(err); actual != expected {
+		t.Errorf("exit code for %q: expected=%d actual=%d", err, expected, actual)
+	}
+}
+
+// testSend sends an email to url without retries.
+func testSend(url string, timeout time.Duration) error {
+	client := newApiClient(url, "api-key-123", timeout, mendsail.RetryPolicy{})
+	_, err := client.Send(context.Background(), &mendsail.Message{To: []string{"foo@example.com"}, Subject: "Test"})
+	return err
+}
+
+func Test_exitCodeFor_ApiErrors(t *testing.T) {
+	statuses := map[int]int{
+		400: 3,
+		401: 4,
+		403: 4,
+		422: 3,
+		429: 5,
+		500: 6,
+		503: 6,
+		504: 8,
+	}
+	for status, expected := range statuses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		err := testSend(server.URL, time.Second)
+		server.Close()
+		expectExitCode(t, expected, err)
+	}
+}
+
+func Test_exitCodeFor_NetworkErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	err := testSend(server.URL, 50*time.Millisecond)
+	expectExitCode(t, 8, err)
+
+	url := server.URL
+	server.Close()
+	err = testSend(url, time.Second)
+	expectExitCode(t, 7, err)
+}
+
+func Test_exitCodeFor_Other(t *testing.T) {
+	expectExitCode(t, 1, errors.New("foobar"))
+	expectExitCode(t, 2, usageError(errors.New("missing value for --to")))
+	expectExitCode(t, 3, fmt.Errorf("wrapped: %w", validationError(errors.New("missing option: --to"))))
+	expectExitCode(t, 3, &docError{file: "message.yaml", line: 1, msg: "foobar"})
+	expectExitCode(t, 42, &exitCodeError{code: 42})
+
+	err := runSend([]string{"--to"})
+	expectError(t, "missing value for --to (argument 1)", err)
+	expectExitCode(t, 2, err)
+	err = runQueue([]string{"foobar"})
+	expectExitCode(t, 2, err)
+}
+
+func Test_isTemporary(t *testing.T) {
+	statuses := map[int]bool{
+		400: false,
+		401: false,
+		403: false,
+		422: false,
+		429: true,
+		500: true,
+		503: true,
+		504: true,
+	}
+	for status, expected := range statuses {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		err := testSend(server.URL, time.Second)
+		server.Close()
+		if isTemporary(err) != expected {
+			t.Errorf("isTemporary for %d: expected=%t actual=%t", status, expected, !expected)
+		}
+	}
+	if isTemporary(validationError(errors.New("missing option: --to"))) {
+		t.Errorf("isTemporary for a validation error: expected=false actual=true")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const redactedText = "[REDACTED]"
+
+var ansiEscapeSequence = regexp.MustCompile(
+	// CSI sequences (colors, cursor movement), e.g. "\x1b[1;31m"
+	`\x1b\[[0-?]*[ -/]*[@-~]` +
+		// OSC sequences (window titles, hyperlinks), terminated by BEL or ST
+		`|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)` +
+		// Character set selection, e.g. "\x1b(B"
+		`|\x1b[()*+][0-9A-Za-z]` +
+		// Other two-character sequences
+		`|\x1b[@-Z\\^_=>78]`)
+
+type redactPattern struct {
+	pattern *regexp.Regexp
+	// If set, the first capture group (e.g. "Bearer ") is kept in front of
+	// the redacted text.
+	keepPrefix bool
+}
+
+var builtinRedactPatterns = []redactPattern{
+	// AWS access key IDs
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), false},
+	// AWS secret access keys in assignments, e.g. "aws_secret_access_key = ..."
+	{regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`), true},
+	// Bearer tokens, e.g. in "Authorization: Bearer ..." headers
+	{regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`), true},
+	// PEM private key blocks
+	{regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?-----END [A-Z0-9 ]*PRIVATE KEY-----`), false},
+}
+
+// The markers of a private key block, which lineRedactor tracks across lines.
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY-----`)
+	// What the lines between the markers look like: base64, or PEM headers
+	// such as "Proc-Type: 4,ENCRYPTED".
+	privateKeyLine = regexp.MustCompile(`^\s*(?:[A-Za-z0-9+/]+=*|(?:Proc-Type|DEK-Info):.*)?\s*$`)
+)
+
+// No more lines than this are dropped after a BEGIN marker without an END
+// marker. Even an 8192-bit RSA key is about 100 lines long.
+const maxPrivateKeyLines = 200
+
+// outputFilter cleans up captured command output before it's sent: terminal
+// escape sequences and carriage-return progress bars are removed, and secrets
+// are replaced with [REDACTED].
+type outputFilter struct {
+	redactPatterns []redactPattern
+}
+
+func validateRedactPattern(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return errors.New("invalid --redact pattern: " + err.Error())
+	}
+	return nil
+}
+
+func newOutputFilter(apiKey string, userPatterns []string) (*outputFilter, error) {
+	filter := &outputFilter{
+		redactPatterns: append([]redactPattern{}, builtinRedactPatterns...),
+	}
+	if apiKey != "" {
+		filter.redactPatterns = append(filter.redactPatterns, redactPattern{
+			pattern: regexp.MustCompile(regexp.QuoteMeta(apiKey)),
+		})
+	}
+	for _, userPattern := range userPatterns {
+		if err := validateRedactPattern(userPattern); err != nil {
+			return nil, err
+		}
+		filter.redactPatterns = append(filter.redactPatterns, redactPattern{
+			pattern: regexp.MustCompile(userPattern),
+		})
+	}
+	return filter, nil
+}
+
+// cleanLine strips escape sequences from a single line and keeps only what a
+// terminal would end up showing after carriage returns.
+func (filter *outputFilter) cleanLine(line string) string {
+	line = ansiEscapeSequence.ReplaceAllString(line, "")
+	line = strings.TrimSuffix(line, "\r")
+	if strings.Contains(line, "\r") {
+		segments := strings.Split(line, "\r")
+		line = ""
+		for i := len(segments) - 1; i >= 0; i-- {
+			if segments[i] != "" {
+				line = segments[i]
+				break
+			}
+		}
+	}
+	return line
+}
+
+func (filter *outputFilter) redact(text string) string {
+	for _, redact := range filter.redactPatterns {
+		if redact.keepPrefix {
+			text = redact.pattern.ReplaceAllString(text, "${1}"+redactedText)
+		} else {
+			text = redact.pattern.ReplaceAllLiteralString(text, redactedText)
+		}
+	}
+	return text
+}
+
+// What readLimitedLines does with a line after filtering it.
+const (
+	lineKeep = iota
+	lineDrop
+)
+
+// lineRedactor cleans and redacts a stream line by line, so that secrets are
+// gone before the stream is cut down to its head and tail. Patterns match
+// within a line, except for private key blocks: everything from a BEGIN marker
+// up to its END marker becomes a single [REDACTED]. A block also ends at the
+// first line that doesn't look like part of a key, or after
+// maxPrivateKeyLines lines, so that a log line that merely mentions the marker
+// doesn't swallow the rest of the output. An END marker without a BEGIN is
+// redacted on its own.
+type lineRedactor struct {
+	filter       *outputFilter
+	inPrivateKey bool
+	keyLines     int
+}
+
+func (filter *outputFilter) newLineRedactor() *lineRedactor {
+	return &lineRedactor{filter: filter}
+}
+
+func (r *lineRedactor) filterLine(line string) (string, int) {
+	line = r.filter.cleanLine(line)
+	if r.inPrivateKey {
+		if end := privateKeyEnd.FindStringIndex(line); end != nil {
+			r.inPrivateKey = false
+			line = line[end[1]:]
+			if strings.TrimSpace(line) == "" {
+				return "", lineDrop
+			}
+		} else if r.keyLines < maxPrivateKeyLines && privateKeyLine.MatchString(line) {
+			r.keyLines++
+			return "", lineDrop
+		} else {
+			r.inPrivateKey = false
+		}
+	}
+	// Complete blocks on a single line are handled by the builtin pattern.
+	line = r.filter.redact(line)
+	line = privateKeyEnd.ReplaceAllLiteralString(line, redactedText)
+	if begin := privateKeyBegin.FindStringIndex(line); begin != nil {
+		line = line[:begin[0]] + redactedText
+		r.inPrivateKey = true
+		r.keyLines = 0
+	}
+	return line, lineKeep
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// An Image block may point at a local file instead of a URL:
+//
+//	--image ./graph.png alt:Load width:600
+//	--image file:///var/lib/graphs/load.png
+//
+// The file is sent as an inline attachment and the block refers to it by
+// content ID. Like --attach, it's read just before the email is sent.
+
+// Larger images are rejected, as they are slow to load and usually a mistake.
+const maxInlineImageSide = 4096
+
+var urlScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// localImagePath returns the file that the url of an Image block points at,
+// if it's a path or a file: URL rather than a remote URL.
+func localImagePath(value string) (string, bool) {
+	if !urlScheme.MatchString(value) {
+		return value, true
+	}
+	if !strings.HasPrefix(strings.ToLower(value), "file:") {
+		return "", false
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return value[len("file:"):], true
+	}
+	if parsed.Opaque != "" {
+		return parsed.Opaque, true
+	}
+	return parsed.Path, true
+}
+
+// resolveMarkdownImages makes the paths of local images in a markdown file
+// relative to the directory of the file.
+func resolveMarkdownImages(blocks []sendBlock, dir string) {
+	for i := range blocks {
+		if blocks[i].blockType != mendsail.BlockTypeImage {
+			continue
+		}
+		if path, ok := localImagePath(blocks[i].url); ok && !filepath.IsAbs(path) {
+			blocks[i].url = filepath.Join(dir, path)
+		}
+	}
+}
+
+// inlineLocalImages adds the local files of Image blocks as inline
+// attachments and points the blocks at them. A file used by several blocks is
+// attached once.
+func inlineLocalImages(options *sendOptions) error {
+	contentIds := make(map[string]string)
+	for i := range options.blocks {
+		block := &options.blocks[i]
+		if block.blockType != mendsail.BlockTypeImage {
+			continue
+		}
+		path, ok := localImagePath(block.url)
+		if !ok {
+			continue
+		}
+		contentId, ok := contentIds[path]
+		if !ok {
+			contentId = fmt.Sprintf("image%d@mendsail", len(contentIds)+1)
+			attachment, err := readInlineImage(path, contentId)
+			if err != nil {
+				return validationError(err)
+			}
+			options.attachments = append(options.attachments, attachment)
+			contentIds[path] = contentId
+		}
+		block.url = mendsail.ContentUrl(contentId)
+	}
+	return nil
+}
+
+// readInlineImage reads the image at path and checks that email clients can
+// show it.
+func readInlineImage(path string, contentId string) (sendAttachment, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return sendAttachment{}, errors.New("could not read image: " + err.Error())
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return sendAttachment{}, errors.New("invalid image " + path + ": should be a PNG, JPEG or GIF file")
+	}
+	if config.Width == 0 || config.Height == 0 || config.Width > maxInlineImageSide || config.Height > maxInlineImageSide {
+		return sendAttachment{}, fmt.Errorf("invalid image %s: %dx%d pixels, should be at most %dx%d", path, config.Width, config.Height, maxInlineImageSide, maxInlineImageSide)
+	}
+	return sendAttachment{
+		path:        path,
+		name:        filepath.Base(path),
+		contentType: "image/" + format,
+		contentId:   contentId,
+		data:        data,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func encodeTestPng(t *testing.T, width int, height int) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func Test_localImagePath(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+		local    bool
+	}{
+		{"graph.png", "graph.png", true},
+		{"./out/graph.png", "./out/graph.png", true},
+		{"/var/lib/graphs/load.png", "/var/lib/graphs/load.png", true},
+		{"file:///var/lib/graphs/load.png", "/var/lib/graphs/load.png", true},
+		{"file:graph.png", "graph.png", true},
+		{"FILE:///tmp/a%20b.png", "/tmp/a b.png", true},
+		{"https://example.com/graph.png", "", false},
+		{"cid:image1@mendsail", "", false},
+		{"data:image/png;base64,AAAA", "", false},
+	}
+	for _, test := range tests {
+		path, local := localImagePath(test.url)
+		if local != test.local || path != test.expected {
+			t.Errorf("localImagePath(%q): expected=%q,%t actual=%q,%t", test.url, test.expected, test.local, path, local)
+		}
+	}
+}
+
+func Test_loadAttachments_InlineImages(t *testing.T) {
+	dir := t.TempDir()
+	graph := encodeTestPng(t, 40, 20)
+	path := writeAttachment(t, dir, "graph.png", graph)
+	options := sendOptions{
+		blocks: []sendBlock{
+			{blockType: "Image", url: path, alt: "Load", width: 600},
+			{blockType: "Image", url: "https://example.com/logo.png"},
+			{blockType: "Image", url: "file://" + path},
+		},
+		attachments: []sendAttachment{
+			{path: writeAttachment(t, dir, "notes.txt", []byte("hello")), contentType: "text/plain"},
+		},
+	}
+	expectNoError(t, loadAttachments(&options))
+	exceptStringsEqual(t, "cid:image1@mendsail", options.blocks[0].url)
+	exceptStringsEqual(t, "Load", options.blocks[0].alt)
+	exceptStringsEqual(t, "https://example.com/logo.png", options.blocks[1].url)
+	exceptStringsEqual(t, "cid:image1@mendsail", options.blocks[2].url)
+	if len(options.attachments) != 2 {
+		t.Fatalf("len(options.attachments): expected=2 actual=%d", len(options.attachments))
+	}
+
+	payload, err := sendOptionsToPayload(options)
+	expectNoError(t, err)
+	expected := mendsail.NewInlineAttachment("graph.png", "image/png", "image1@mendsail", graph)
+	if payload.Attachments[1] != expected {
+		t.Errorf("payload.Attachments[1]: expected=%v actual=%v", expected, payload.Attachments[1])
+	}
+}
+
+func Test_loadAttachments_InlineImageErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"graph.svg", []byte("<svg></svg>"), "invalid image " + filepath.Join(dir, "graph.svg") + ": should be a PNG, JPEG or GIF file"},
+		{"wide.png", encodeTestPng(t, 5000, 10), "invalid image " + filepath.Join(dir, "wide.png") + ": 5000x10 pixels, should be at most 4096x4096"},
+	}
+	for _, test := range tests {
+		options := sendOptions{blocks: []sendBlock{{blockType: "Image", url: writeAttachment(t, dir, test.name, test.data)}}}
+		err := loadAttachments(&options)
+		expectError(t, test.expected, err)
+		expectExitCode(t, 3, err)
+	}
+
+	missing := filepath.Join(dir, "missing.png")
+	options := sendOptions{blocks: []sendBlock{{blockType: "Image", url: missing}}}
+	expectError(t, "could not read image: open "+missing+": no such file or directory", loadAttachments(&options))
+
+	options = sendOptions{
+		attachMaxBytes: 10,
+		blocks:         []sendBlock{{blockType: "Image", url: writeAttachment(t, dir, "graph.png", encodeTestPng(t, 1, 1))}},
+	}
+	err := loadAttachments(&options)
+	if err == nil {
+		t.Errorf("expected inline images to count towards --attach-max-bytes")
+	}
+}
+
+func Test_resolveMarkdownImages(t *testing.T) {
+	blocks := []sendBlock{
+		{blockType: "Image", url: "graph.png"},
+		{blockType: "Image", url: "/tmp/graph.png"},
+		{blockType: "Image", url: "https://example.com/graph.png"},
+		{blockType: "Paragraph", text: "graph.png"},
+	}
+	resolveMarkdownImages(blocks, "reports")
+	exceptStringsEqual(t, filepath.Join("reports", "graph.png"), blocks[0].url)
+	exceptStringsEqual(t, "/tmp/graph.png", blocks[1].url)
+	exceptStringsEqual(t, "https://example.com/graph.png", blocks[2].url)
+	exceptStringsEqual(t, "graph.png", blocks[3].text)
+}
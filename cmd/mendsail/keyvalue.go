@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// KeyValue blocks list job metadata such as host and duration. Consecutive
+// --kv options add to the same block:
+//
+//	--kv host=web-1 --kv job=backup --paragraph ... --kv exit=0
+//
+// makes two blocks. --kv-json reads the pairs from a flat JSON object (or
+// stdin) instead, and is kept as a placeholder until expandSourceBlocks.
+const kvJsonSourceBlockType = "kv-json"
+
+// parseKv splits "key=value".
+func parseKv(value string) (mendsail.Pair, error) {
+	separator := strings.Index(value, "=")
+	if separator < 1 || strings.TrimSpace(value[:separator]) == "" {
+		return mendsail.Pair{}, errors.New("invalid value for --kv: '" + value + "' (should be key=value)")
+	}
+	return mendsail.Pair{Key: strings.TrimSpace(value[:separator]), Value: value[separator+1:]}, nil
+}
+
+// addKv adds pair to the KeyValue block at the end of blocks, or starts a new
+// one if the last block is something else.
+func addKv(blocks *[]sendBlock, pair mendsail.Pair) error {
+	last := len(*blocks) - 1
+	if last < 0 || (*blocks)[last].blockType != mendsail.BlockTypeKeyValue {
+		*blocks = append(*blocks, sendBlock{blockType: mendsail.BlockTypeKeyValue})
+		last++
+	}
+	block := &(*blocks)[last]
+	for _, existing := range block.pairs {
+		if existing.Key == pair.Key {
+			return errors.New("duplicate key for --kv: '" + pair.Key + "'")
+		}
+	}
+	block.pairs = append(block.pairs, pair)
+	return nil
+}
+
+// docToPairs reads a flat mapping of keys to values, in the order given.
+func docToPairs(node *docNode) ([]mendsail.Pair, error) {
+	if node.kind != docMap {
+		return nil, errors.New("expected a flat object of keys to values")
+	}
+	pairs := make([]mendsail.Pair, 0, len(node.keys))
+	for _, key := range node.keys {
+		value, err := docString(node.fields[key])
+		if err != nil {
+			return nil, errors.New("'" + key + "' " + err.Error())
+		}
+		pairs = append(pairs, mendsail.Pair{Key: key, Value: value})
+	}
+	return pairs, nil
+}
+
+// readKvJson parses the source of --kv-json. fileName is used in errors.
+func readKvJson(fileName string, source []byte) ([]mendsail.Pair, error) {
+	root, err := parseJsonDocument(source)
+	var docErr *docError
+	if errors.As(err, &docErr) {
+		docErr.file = fileName
+		return nil, docErr
+	}
+	if err != nil {
+		return nil, &docError{file: fileName, msg: err.Error()}
+	}
+	pairs, err := docToPairs(root)
+	if err != nil {
+		return nil, &docError{file: fileName, line: root.line, msg: err.Error()}
+	}
+	return pairs, nil
+}
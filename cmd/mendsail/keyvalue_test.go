@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func Test_parseSendArgs_Kv(t *testing.T) {
+	args := []string{
+		"--kv", "host=web-1", "--kv", "job=backup", "--to", "foobar@example.com", "--kv", "url=https://example.com/?a=b",
+		"--paragraph", "paragraph 1",
+		"--kv", "exit=0",
+	}
+	expected := sendOptions{
+		to: []string{"foobar@example.com"},
+		blocks: []sendBlock{
+			{blockType: "KeyValue", pairs: []mendsail.Pair{
+				{Key: "host", Value: "web-1"},
+				{Key: "job", Value: "backup"},
+				{Key: "url", Value: "https://example.com/?a=b"},
+			}},
+			{blockType: "Paragraph", text: "paragraph 1"},
+			{blockType: "KeyValue", pairs: []mendsail.Pair{{Key: "exit", Value: "0"}}},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_KvErrors(t *testing.T) {
+	_, err := parseSendArgs([]string{"--kv", "host"})
+	expectError(t, "invalid value for --kv: 'host' (should be key=value) (argument 1)", err)
+	_, err = parseSendArgs([]string{"--kv", "=web-1"})
+	expectError(t, "invalid value for --kv: '=web-1' (should be key=value) (argument 1)", err)
+	_, err = parseSendArgs([]string{"--kv", "host=a", "--kv", "host=b"})
+	expectError(t, "duplicate key for --kv: 'host' (argument 3)", err)
+}
+
+func Test_readKvJson(t *testing.T) {
+	pairs, err := readKvJson("info.json", []byte("{\"job\": \"backup\", \"attempt\": 2, \"dry\": false}"))
+	expectNoError(t, err)
+	expected := []mendsail.Pair{{Key: "job", Value: "backup"}, {Key: "attempt", Value: "2"}, {Key: "dry", Value: "false"}}
+	if len(pairs) != len(expected) {
+		t.Fatalf("pairs: expected=%v actual=%v", expected, pairs)
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Errorf("pairs[%d]: expected=%v actual=%v", i, expected[i], pairs[i])
+		}
+	}
+
+	_, err = readKvJson("info.json", []byte("{\n  \"job\": {\"name\": \"backup\"}\n}"))
+	expectError(t, "info.json:1: 'job' should be a single value", err)
+	_, err = readKvJson("info.json", []byte("[1, 2]"))
+	expectError(t, "info.json:1: expected a flat object of keys to values", err)
+	_, err = readKvJson("stdin", []byte("{\"job\": "))
+	expectError(t, "stdin:1: EOF", err)
+}
+
+func Test_expandSourceBlocks_KvJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "info.json")
+	expectNoError(t, os.WriteFile(path, []byte("{\"commit\": \"abc123\"}"), 0644))
+	options := sendOptions{
+		blocks: []sendBlock{{blockType: kvJsonSourceBlockType, text: path}},
+	}
+	_, err := expandSourceBlocks(&options, false, nil, &bytes.Buffer{})
+	expectNoError(t, err)
+	expected := sendOptions{
+		blocks: []sendBlock{{blockType: "KeyValue", pairs: []mendsail.Pair{{Key: "commit", Value: "abc123"}}}},
+	}
+	exceptOptions(t, expected, &options, nil)
+}
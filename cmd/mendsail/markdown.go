@@ -0,0 +1,443 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Markdown sources given with --markdown are kept as placeholder blocks
+// (with the path in text) until expandSourceBlocks replaces them with the
+// blocks parsed from the file.
+const markdownSourceBlockType = "markdown"
+
+// Maps GitHub-style callouts ("> [!WARNING]") to Alert styles.
+var markdownCalloutStyles = map[string]string{
+	"NOTE":      "info",
+	"INFO":      "info",
+	"IMPORTANT": "info",
+	"TIP":       "success",
+	"SUCCESS":   "success",
+	"WARNING":   "warning",
+	"CAUTION":   "danger",
+	"DANGER":    "danger",
+}
+
+var (
+	markdownAtxHeading     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	markdownSetextLine     = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	markdownFence          = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	markdownThematicBreak  = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	markdownListItem       = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	markdownBlockquote     = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	markdownCallout        = regexp.MustCompile(`^\[!([A-Za-z]+)\][ \t]*(.*)$`)
+	markdownHtmlBlock      = regexp.MustCompile(`^ {0,3}<(/?[A-Za-z][A-Za-z0-9-]*|!--)`)
+	markdownTableDelimiter = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	markdownImageLine      = regexp.MustCompile(`^!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)$`)
+	markdownLinkLine       = regexp.MustCompile(`^\[([^\]]+)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)$`)
+	markdownAutolinkLine   = regexp.MustCompile(`^<((?:https?|mailto):[^>\s]+)>$`)
+
+	markdownInlineImage  = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownInlineLink   = regexp.MustCompile(`\[([^\]]+)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	markdownAutolink     = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	markdownStrong       = regexp.MustCompile(`(\*\*|__)([^\s*_](?:.*?[^\s])?)(\*\*|__)`)
+	markdownEmphasis     = regexp.MustCompile(`(^|[^\w*])[*_]([^\s*_](?:[^*_]*?[^\s*_])?)[*_]`)
+	markdownStrike       = regexp.MustCompile(`~~([^~]+)~~`)
+	markdownCodeSpan     = regexp.MustCompile("`+([^`]+)`+")
+	markdownEscape       = regexp.MustCompile(`\\([!"#$%&'()*+,\-./:;<=>?@\[\\\]^_{|}~` + "`" + `])`)
+	markdownHardBreakEnd = regexp.MustCompile(`( {2,}|\\)$`)
+)
+
+type markdownParser struct {
+	lines    []string
+	pos      int
+	blocks   []sendBlock
+	warnings []string
+}
+
+// markdownToBlocks converts CommonMark into Mendsail blocks. Constructs that
+// have no matching block type are sent as paragraphs and reported in the
+// returned warnings.
+func markdownToBlocks(source string) ([]sendBlock, []string) {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	parser := &markdownParser{
+		lines:  strings.Split(strings.ReplaceAll(source, "\t", "    "), "\n"),
+		blocks: make([]sendBlock, 0),
+	}
+	for parser.pos < len(parser.lines) {
+		parser.parseBlock()
+	}
+	return parser.blocks, parser.warnings
+}
+
+func (p *markdownParser) warn(line int, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, fmt.Sprintf("line %d: ", line+1)+fmt.Sprintf(format, args...))
+}
+
+func (p *markdownParser) add(blockType string, text string) {
+	p.blocks = append(p.blocks, sendBlock{blockType: blockType, text: text})
+}
+
+func (p *markdownParser) parseBlock() {
+	line := p.lines[p.pos]
+	start := p.pos
+
+	switch {
+	case strings.TrimSpace(line) == "":
+		p.pos++
+	case markdownFence.MatchString(line):
+		p.parseFencedCode()
+	case markdownAtxHeading.MatchString(line):
+		p.add(mendsail.BlockTypeHeading, markdownInlineToText(markdownAtxHeading.FindStringSubmatch(line)[2]))
+		p.pos++
+	case markdownThematicBreak.MatchString(line):
+		p.blocks = append(p.blocks, sendBlock{blockType: mendsail.BlockTypeDivider})
+		p.pos++
+	case markdownListItem.MatchString(line) && leadingSpaces(line) < 4:
+		p.parseList()
+	case markdownBlockquote.MatchString(line):
+		p.parseBlockquote()
+	case leadingSpaces(line) >= 4:
+		p.parseIndentedCode()
+	case markdownHtmlBlock.MatchString(line):
+		p.add(mendsail.BlockTypeParagraph, strings.Join(p.takeUntilBlank(), "\n"))
+		p.warn(start, "HTML blocks are not supported, sent as a paragraph")
+	case p.atTable():
+		p.parseTable()
+	default:
+		p.parseParagraph()
+	}
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func (p *markdownParser) takeUntilBlank() []string {
+	lines := make([]string, 0)
+	for p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) != "" {
+		lines = append(lines, strings.TrimSpace(p.lines[p.pos]))
+		p.pos++
+	}
+	return lines
+}
+
+func (p *markdownParser) parseFencedCode() {
+	match := markdownFence.FindStringSubmatch(p.lines[p.pos])
+	indent := len(match[1])
+	fence := match[2]
+	p.pos++
+
+	code := make([]string, 0)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" && leadingSpaces(line) < 4 {
+			break
+		}
+		if leadingSpaces(line) >= indent {
+			line = line[indent:]
+		} else {
+			line = strings.TrimLeft(line, " ")
+		}
+		code = append(code, line)
+	}
+	p.add(mendsail.BlockTypeCodeBlock, strings.Join(code, "\n"))
+}
+
+func (p *markdownParser) parseIndentedCode() {
+	code := make([]string, 0)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) != "" && leadingSpaces(line) < 4 {
+			break
+		}
+		if len(line) >= 4 {
+			line = line[4:]
+		} else {
+			line = ""
+		}
+		code = append(code, line)
+		p.pos++
+	}
+	for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+		code = code[:len(code)-1]
+	}
+	p.add(mendsail.BlockTypeCodeBlock, strings.Join(code, "\n"))
+}
+
+// atTable tells whether a GitHub-style table starts at the current line: a
+// header row followed by a delimiter row such as "| --- | :-: |" with as many
+// cells.
+func (p *markdownParser) atTable() bool {
+	if p.pos+1 >= len(p.lines) {
+		return false
+	}
+	header, delimiter := p.lines[p.pos], p.lines[p.pos+1]
+	return strings.Contains(header, "|") && strings.Contains(delimiter, "|") &&
+		markdownTableDelimiter.MatchString(delimiter) &&
+		len(splitMarkdownTableRow(header)) == len(splitMarkdownTableRow(delimiter))
+}
+
+// parseTable reads a table up to a blank line or the start of another block.
+// Rows with too few cells are padded and extra cells are dropped, as on
+// GitHub. Column alignment is not kept.
+func (p *markdownParser) parseTable() {
+	headers := splitMarkdownTableRow(p.lines[p.pos])
+	p.pos += 2
+	rows := make([][]string, 0)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" || !isMarkdownLazyContinuation(line) {
+			break
+		}
+		row := make([]string, len(headers))
+		copy(row, splitMarkdownTableRow(line))
+		rows = append(rows, row)
+		p.pos++
+	}
+	for i := range headers {
+		headers[i] = markdownInlineToText(headers[i])
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = markdownInlineToText(row[i])
+		}
+	}
+	p.blocks = append(p.blocks, sendBlock{blockType: mendsail.BlockTypeTable, headers: headers, rows: rows})
+}
+
+// splitMarkdownTableRow splits a table row at the pipes that aren't escaped,
+// ignoring a leading and a trailing one.
+func splitMarkdownTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	cells := make([]string, 0)
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			// Left for markdownInlineToText to unescape.
+			cell.WriteString("\\|")
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func (p *markdownParser) parseList() {
+	start := p.pos
+	baseIndent := leadingSpaces(p.lines[p.pos])
+	marker := markdownListMarker(p.lines[p.pos])
+	items := make([]string, 0)
+	nested := false
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			// A blank line continues the list only if another item follows.
+			next := p.pos + 1
+			if next < len(p.lines) && markdownListMarker(p.lines[next]) == marker {
+				p.pos++
+				continue
+			}
+			break
+		}
+		if match := markdownListItem.FindStringSubmatch(line); match != nil && !markdownThematicBreak.MatchString(line) {
+			if len(match[1]) > baseIndent+1 {
+				nested = true
+			} else if len(match[1]) < baseIndent || markdownListMarker(line) != marker {
+				// A different bullet character or ordered delimiter starts a
+				// new list.
+				break
+			}
+			items = append(items, strings.TrimSpace(match[3]))
+			p.pos++
+			continue
+		}
+		if len(items) == 0 || (leadingSpaces(line) <= baseIndent && !isMarkdownLazyContinuation(line)) {
+			break
+		}
+		items[len(items)-1] += " " + strings.TrimSpace(line)
+		p.pos++
+	}
+	for i := range items {
+		items[i] = markdownInlineToText(items[i])
+	}
+	if nested {
+		p.warn(start, "nested lists are not supported, flattened into a single list")
+	}
+	p.blocks = append(p.blocks, sendBlock{blockType: mendsail.BlockTypeList, items: items})
+}
+
+// markdownListMarker returns the bullet character or, for ordered lists, the
+// delimiter after the number. Empty if line isn't a list item.
+func markdownListMarker(line string) string {
+	match := markdownListItem.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	return match[2][len(match[2])-1:]
+}
+
+// A lazy continuation line continues a list item's paragraph without being
+// indented, as long as it doesn't start a new block.
+func isMarkdownLazyContinuation(line string) bool {
+	return !markdownAtxHeading.MatchString(line) &&
+		!markdownFence.MatchString(line) &&
+		!markdownBlockquote.MatchString(line) &&
+		!markdownThematicBreak.MatchString(line) &&
+		!markdownHtmlBlock.MatchString(line)
+}
+
+func (p *markdownParser) parseBlockquote() {
+	start := p.pos
+	lines := make([]string, 0)
+	for p.pos < len(p.lines) {
+		match := markdownBlockquote.FindStringSubmatch(p.lines[p.pos])
+		if match == nil {
+			break
+		}
+		lines = append(lines, match[1])
+		p.pos++
+	}
+
+	if callout := markdownCallout.FindStringSubmatch(strings.TrimSpace(lines[0])); callout != nil {
+		if style, ok := markdownCalloutStyles[strings.ToUpper(callout[1])]; ok {
+			lines[0] = callout[2]
+			p.blocks = append(p.blocks, sendBlock{
+				blockType: mendsail.BlockTypeAlert,
+				text:      markdownParagraphsToText(lines),
+				style:     style,
+			})
+			return
+		}
+		p.warn(start, "unknown callout type '%s', sent as a paragraph", callout[1])
+	} else {
+		p.warn(start, "block quotes are not supported, sent as a paragraph")
+	}
+	p.add(mendsail.BlockTypeParagraph, markdownParagraphsToText(lines))
+}
+
+func (p *markdownParser) parseParagraph() {
+	lines := make([]string, 0)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if len(lines) > 0 {
+			if match := markdownSetextLine.FindStringSubmatch(line); match != nil {
+				p.pos++
+				p.add(mendsail.BlockTypeHeading, markdownInlineToText(strings.Join(trimLines(lines), " ")))
+				return
+			}
+			if markdownAtxHeading.MatchString(line) || markdownFence.MatchString(line) ||
+				markdownBlockquote.MatchString(line) || markdownThematicBreak.MatchString(line) ||
+				(markdownListItem.MatchString(line) && leadingSpaces(line) < 4) {
+				break
+			}
+		}
+		lines = append(lines, line)
+		p.pos++
+	}
+
+	if len(lines) == 1 {
+		text := strings.TrimSpace(lines[0])
+		if match := markdownImageLine.FindStringSubmatch(text); match != nil {
+			p.blocks = append(p.blocks, sendBlock{blockType: mendsail.BlockTypeImage, url: match[2], alt: match[1]})
+			return
+		}
+		if match := markdownLinkLine.FindStringSubmatch(text); match != nil {
+			p.blocks = append(p.blocks, sendBlock{
+				blockType: mendsail.BlockTypeLink,
+				url:       match[2],
+				text:      markdownInlineToText(match[1]),
+			})
+			return
+		}
+		if match := markdownAutolinkLine.FindStringSubmatch(text); match != nil {
+			p.blocks = append(p.blocks, sendBlock{blockType: mendsail.BlockTypeLink, url: match[1], text: match[1]})
+			return
+		}
+	}
+	p.add(mendsail.BlockTypeParagraph, markdownParagraphsToText(lines))
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, line := range lines {
+		trimmed[i] = strings.TrimSpace(line)
+	}
+	return trimmed
+}
+
+// markdownParagraphsToText joins soft-wrapped lines with spaces, keeps hard
+// line breaks and separates paragraphs with a blank line.
+func markdownParagraphsToText(lines []string) string {
+	var builder strings.Builder
+	pendingBreak := ""
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			if builder.Len() > 0 {
+				pendingBreak = "\n\n"
+			}
+			continue
+		}
+		builder.WriteString(pendingBreak)
+		hardBreak := markdownHardBreakEnd.MatchString(line)
+		line = strings.TrimSpace(line)
+		if hardBreak {
+			line = strings.TrimSuffix(line, "\\")
+			pendingBreak = "\n"
+		} else {
+			pendingBreak = " "
+		}
+		builder.WriteString(markdownInlineToText(line))
+	}
+	return builder.String()
+}
+
+// markdownInlineToText removes inline formatting, since blocks only hold
+// plain text. Links keep their target in parentheses.
+func markdownInlineToText(text string) string {
+	// Code spans and escaped characters are set aside so that the formatting
+	// rules below don't touch them.
+	protected := make([]string, 0)
+	protect := func(value string) string {
+		protected = append(protected, value)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+	text = markdownCodeSpan.ReplaceAllStringFunc(text, func(span string) string {
+		return protect(strings.TrimSpace(strings.Trim(span, "`")))
+	})
+	text = markdownEscape.ReplaceAllStringFunc(text, func(escape string) string {
+		return protect(escape[1:])
+	})
+	text = markdownInlineImage.ReplaceAllString(text, "$1")
+	text = markdownInlineLink.ReplaceAllStringFunc(text, func(link string) string {
+		match := markdownInlineLink.FindStringSubmatch(link)
+		if match[1] == match[2] {
+			return match[2]
+		}
+		return match[1] + " (" + match[2] + ")"
+	})
+	text = markdownAutolink.ReplaceAllString(text, "$1")
+	text = markdownStrong.ReplaceAllString(text, "$2")
+	text = markdownEmphasis.ReplaceAllString(text, "$1$2")
+	text = markdownStrike.ReplaceAllString(text, "$1")
+	for i, value := range protected {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), value, 1)
+	}
+	return text
+}
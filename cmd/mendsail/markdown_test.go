@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_markdownToBlocks_Basic(t *testing.T) {
+	source := "# Nightly report\n" +
+		"\n" +
+		"All jobs **finished**, see\n" +
+		"[the dashboard](https://example.com) for `details`.\n" +
+		"\n" +
+		"- item 1\n" +
+		"- item *2*\n" +
+		"  continued\n" +
+		"\n" +
+		"1. first\n" +
+		"2. second\n" +
+		"\n" +
+		"```sh\n" +
+		"echo hello\n" +
+		"\n" +
+		"```\n" +
+		"![Disk usage](https://example.com/disk.png)\n" +
+		"\n" +
+		"[Open runbook](https://example.com/runbook)\n" +
+		"\n" +
+		"Summary\n" +
+		"-------\n"
+	blocks, warnings := markdownToBlocks(source)
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Nightly report"},
+			sendBlock{blockType: "Paragraph", text: "All jobs finished, see the dashboard (https://example.com) for details."},
+			sendBlock{blockType: "List", items: []string{"item 1", "item 2 continued"}},
+			sendBlock{blockType: "List", items: []string{"first", "second"}},
+			sendBlock{blockType: "CodeBlock", text: "echo hello\n"},
+			sendBlock{blockType: "Image", url: "https://example.com/disk.png", alt: "Disk usage"},
+			sendBlock{blockType: "Link", url: "https://example.com/runbook", text: "Open runbook"},
+			sendBlock{blockType: "Heading", text: "Summary"},
+		},
+	}
+	exceptOptions(t, expected, &sendOptions{blocks: blocks}, nil)
+	if len(warnings) != 0 {
+		t.Errorf("warnings: expected=none actual=%s", warnings)
+	}
+}
+
+func Test_markdownToBlocks_Callouts(t *testing.T) {
+	source := "> [!WARNING]\n" +
+		"> Disk is almost full.\n" +
+		"> Clean up soon.\n" +
+		"\n" +
+		"> [!CAUTION] Backups failed\n" +
+		"\n" +
+		"> [!TIP]\n" +
+		"> Run with --verbose\n"
+	blocks, warnings := markdownToBlocks(source)
+	if len(blocks) != 3 {
+		t.Fatalf("len(blocks): expected=%d actual=%d", 3, len(blocks))
+	}
+	exceptStringsEqual(t, "Alert", blocks[0].blockType)
+	exceptStringsEqual(t, "warning", blocks[0].style)
+	exceptStringsEqual(t, "Disk is almost full. Clean up soon.", blocks[0].text)
+	exceptStringsEqual(t, "danger", blocks[1].style)
+	exceptStringsEqual(t, "Backups failed", blocks[1].text)
+	exceptStringsEqual(t, "success", blocks[2].style)
+	if len(warnings) != 0 {
+		t.Errorf("warnings: expected=none actual=%s", warnings)
+	}
+}
+
+func Test_markdownToBlocks_UnsupportedConstructs(t *testing.T) {
+	source := "> just a quote\n" +
+		"\n" +
+		"<div>html</div>\n" +
+		"\n" +
+		"- outer\n" +
+		"    - inner\n"
+	blocks, warnings := markdownToBlocks(source)
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "just a quote"},
+			sendBlock{blockType: "Paragraph", text: "<div>html</div>"},
+			sendBlock{blockType: "List", items: []string{"outer", "inner"}},
+		},
+	}
+	exceptOptions(t, expected, &sendOptions{blocks: blocks}, nil)
+	expectedWarnings := []string{
+		"line 1: block quotes are not supported, sent as a paragraph",
+		"line 3: HTML blocks are not supported, sent as a paragraph",
+		"line 5: nested lists are not supported, flattened into a single list",
+	}
+	if !reflect.DeepEqual(expectedWarnings, warnings) {
+		t.Errorf("warnings: expected=%q actual=%q", expectedWarnings, warnings)
+	}
+}
+
+func Test_markdownToBlocks_Tables(t *testing.T) {
+	source := "| Host | Disk |\n" +
+		"| :--- | ---: |\n" +
+		"| `web-1` | **91%** |\n" +
+		"| db-1 |\n" +
+		"| a \\| b | 1 | extra |\n" +
+		"\n" +
+		"Name | Status\n" +
+		"--- | ---\n" +
+		"backup | ok\n" +
+		"# After\n" +
+		"\n" +
+		"| not | a table |\n"
+	blocks, warnings := markdownToBlocks(source)
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Table", headers: []string{"Host", "Disk"}, rows: [][]string{
+				{"web-1", "91%"},
+				{"db-1", ""},
+				{"a | b", "1"},
+			}},
+			sendBlock{blockType: "Table", headers: []string{"Name", "Status"}, rows: [][]string{{"backup", "ok"}}},
+			sendBlock{blockType: "Heading", text: "After"},
+			sendBlock{blockType: "Paragraph", text: "| not | a table |"},
+		},
+	}
+	exceptOptions(t, expected, &sendOptions{blocks: blocks}, nil)
+	if len(warnings) != 0 {
+		t.Errorf("warnings: expected=none actual=%s", warnings)
+	}
+}
+
+func Test_markdownToBlocks_ThematicBreaks(t *testing.T) {
+	source := "Intro\n" +
+		"\n" +
+		"---\n" +
+		"***\n" +
+		"___\n" +
+		" * * *\n" +
+		"\n" +
+		"Setext heading\n" +
+		"---\n"
+	blocks, warnings := markdownToBlocks(source)
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "Intro"},
+			sendBlock{blockType: "Divider"},
+			sendBlock{blockType: "Divider"},
+			sendBlock{blockType: "Divider"},
+			sendBlock{blockType: "Divider"},
+			sendBlock{blockType: "Heading", text: "Setext heading"},
+		},
+	}
+	exceptOptions(t, expected, &sendOptions{blocks: blocks}, nil)
+	if len(warnings) != 0 {
+		t.Errorf("warnings: expected=none actual=%s", warnings)
+	}
+}
+
+func Test_markdownInlineToText(t *testing.T) {
+	tests := map[string]string{
+		"**bold** and _italic_":         "bold and italic",
+		"snake_case_name stays":         "snake_case_name stays",
+		"`a * b` is code":               "a * b is code",
+		"<https://example.com>":         "https://example.com",
+		"[https://x.io](https://x.io)":  "https://x.io",
+		"~~old~~ new":                   "old new",
+		"escaped \\*stars\\*":           "escaped *stars*",
+		"inline ![img](https://x.io/a)": "inline img",
+	}
+	for input, expected := range tests {
+		exceptStringsEqual(t, expected, markdownInlineToText(input))
+	}
+}
+
+func Test_expandSourceBlocks_Stdin(t *testing.T) {
+	options := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Report"},
+			sendBlock{blockType: markdownSourceBlockType, text: "-"},
+		},
+	}
+	var warnings bytes.Buffer
+	usedStdin, err := expandSourceBlocks(&options, true, []byte("hello\n\n> quote\n"), &warnings)
+	expectNoError(t, err)
+	if !usedStdin {
+		t.Errorf("usedStdin: expected=true actual=false")
+	}
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Report"},
+			sendBlock{blockType: "Paragraph", text: "hello"},
+			sendBlock{blockType: "Paragraph", text: "quote"},
+		},
+	}
+	exceptOptions(t, expected, &options, nil)
+	exceptStringsEqual(t, "warning: stdin: line 3: block quotes are not supported, sent as a paragraph\n", warnings.String())
+}
+
+func Test_expandSourceBlocks_NoStdin(t *testing.T) {
+	options := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: markdownSourceBlockType, text: "-"},
+		},
+	}
+	_, err := expandSourceBlocks(&options, false, nil, &bytes.Buffer{})
+	expectError(t, "--markdown -: nothing was piped into stdin", err)
+}
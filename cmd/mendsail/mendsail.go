@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type showHelpType func() error
+
+func showHelp() error {
+	var builder strings.Builder
+	builder.WriteString("Usage:\n")
+	for _, command := range commandSpecs {
+		for _, usage := range command.usage {
+			builder.WriteString("  $ " + usage + "\n")
+		}
+	}
+	for _, group := range helpOptionGroups {
+		builder.WriteString("\n")
+		writeOptionGroup(&builder, group)
+	}
+	builder.WriteString("\n")
+
+	builder.WriteString("" +
+		"Option syntax:\n" +
+		"  Values can be given as --subject Hello or --subject=Hello, and -t, -s and -k\n" +
+		"  are short for --to, --subject and --api-key. A value that looks like an\n" +
+		"  option needs the = form or a -- before it; everything after -- is taken as\n" +
+		"  values as is. Lists and block options such as style: end at the next option\n" +
+		"  or at --end. Errors name the position of the offending argument. In run,\n" +
+		"  the first -- starts the command, so values there need the = form instead.\n" +
+		"    $ mendsail send -t admin@example.com --paragraph=--verbose --list a b --end\n" +
+		"\n" +
+		"stdout/stdin:\n" +
+		"  If you pipe stdout output into mendsail, that output will be appended to the\n" +
+		"  email as a CodeBlock (see --stdin-as and --stdin-position). Lines left out by\n" +
+		"  --stdin-head, --stdin-tail or --stdin-max-bytes are replaced with a\n" +
+		"  \"… N lines truncated …\" marker. Example usage:\n" +
+		"    $ bash script.sh | mendsail --to admin@example.com --alert \"Script output\"\n" +
+		"    $ tail -n50 log.txt | mendsail --to admin@example.com --heading \"Recent logs\"\n" +
+		"    $ make 2>&1 | mendsail --to admin@example.com --stdin-tail 100 --stdin-position 1\n" +
+		"\n" +
+		"  To convert piped Markdown into blocks instead, use --markdown -:\n" +
+		"    $ cat report.md | mendsail --to admin@example.com --markdown -\n" +
+		"\n" +
+		"  Terminal escape sequences and carriage-return progress bars are removed from\n" +
+		"  stdin and from the output of run. AWS keys, bearer tokens, private keys, the\n" +
+		"  API key itself and anything matching --redact are replaced with [REDACTED],\n" +
+		"  before the head, tail and byte limits apply. --redact patterns are matched\n" +
+		"  within a line.\n" +
+		"\n" +
+		"JSON output:\n" +
+		"  With --output json, send prints {\"id\", \"status\", \"recipients\", \"attempts\"} on\n" +
+		"  success and {\"error\": {\"httpStatus\", \"code\", \"message\"}} on failure, both to\n" +
+		"  stdout. Together with --dry-run, only the request payload is printed.\n" +
+		"\n" +
+		"Previews:\n" +
+		"  --preview renders the email locally, roughly as recipients will see it, and\n" +
+		"  doesn't need an API key. With --preview-file alone, the format follows the\n" +
+		"  file extension (.txt for text, anything else for html):\n" +
+		"    $ mendsail send --to a@example.com --subject Test --alert Hi --preview-file mail.html\n" +
+		"\n" +
+		"Markdown:\n" +
+		"  Headings, paragraphs, lists, fenced code, images, standalone links, tables\n" +
+		"  and thematic breaks (---) are converted into the matching blocks, and\n" +
+		"  callouts such as \"> [!WARNING]\" into alerts. Other constructs are sent as\n" +
+		"  paragraphs with a warning.\n" +
+		"\n" +
+		"Tables:\n" +
+		"  --table takes the header row and any number of row: sub-options, each with\n" +
+		"  one comma-separated cell per header (quote a cell containing a comma as in\n" +
+		"  CSV). --table-csv and --table-tsv read the header row and the rows from a\n" +
+		"  file, or from stdin with -. --dry-run also prints tables as plain text:\n" +
+		"    $ mendsail send ... --table Host,Disk row:web-1,91% row:db-1,45%\n" +
+		"    $ df -h | tr -s ' ' '\\t' | mendsail send ... --table-tsv -\n" +
+		"\n" +
+		"Key/value pairs:\n" +
+		"  Consecutive --kv options make one KeyValue block, listing the pairs in the\n" +
+		"  given order. --kv-json reads the pairs from a flat JSON object instead:\n" +
+		"    $ mendsail send ... --kv host=$(hostname) --kv job=backup --kv exit=$?\n" +
+		"    $ mendsail send ... --kv-json build-info.json\n" +
+		"\n" +
+		"Attachments:\n" +
+		"  --attach reads the file just before sending, so run can attach a file the\n" +
+		"  command wrote. The MIME type is guessed from the extension, then from the\n" +
+		"  content. Attachments may add up to 10 MiB by default, counted base64-encoded\n" +
+		"  as they are sent (about 4/3 of the file size). --attach-max-bytes changes\n" +
+		"  the limit, or turns it off with \"unlimited\", on the command line and in a\n" +
+		"  profile alike. --attach-compress gzips large text files:\n" +
+		"    $ mendsail run ... --attach build.log --attach-compress -- make\n" +
+		"    $ mendsail send ... --attach out/report.csv name:report-$(date +%F).csv\n" +
+		"\n" +
+		"Images:\n" +
+		"  --image also takes a path or a file: URL. The image is then sent as an\n" +
+		"  inline attachment, and must be a PNG, JPEG or GIF of at most 4096x4096\n" +
+		"  pixels. Images in a --markdown file are looked up next to the file:\n" +
+		"    $ mendsail send ... --image ./graph.png alt:\"Load average\" width:600\n" +
+		"\n" +
+		"run:\n" +
+		"  Runs the command, then emails its exit status, duration and captured\n" +
+		"  stdout/stderr. mendsail exits with the same status as the command, or with\n" +
+		"  128 plus the signal number if the command was killed by a signal. Each\n" +
+		"  stream is cut down with --stdin-head, --stdin-tail and --stdin-max-bytes\n" +
+		"  while the command runs, keeping the last 200 lines and 64 KiB by default.\n" +
+		"    $ mendsail run --to admin@example.com --on-failure-only -- ./backup.sh\n" +
+		"\n" +
+		"queue:\n" +
+		"  With --spool-on-failure, emails that can't be delivered are written to\n" +
+		"  $XDG_STATE_HOME/mendsail/spool (~/.local/state/mendsail/spool by default).\n" +
+		"  Only temporary failures are queued: network errors, timeouts, 429 and 5xx.\n" +
+		"  \"queue flush\" sends them again, \"queue list\" shows them and \"queue purge\"\n" +
+		"  deletes them. Each email is sent with the API key of the profile it was\n" +
+		"  queued under. Emails the API rejects during a flush are moved to the\n" +
+		"  rejected subdirectory, where \"queue list\" shows them and \"queue purge\"\n" +
+		"  deletes them too. A flush while another one is running does nothing.\n" +
+		"\n" +
+		"Retries:\n" +
+		"  Network errors and 408, 429, 500, 502, 503 and 504 responses are retried with\n" +
+		"  exponential backoff, honoring Retry-After. Every attempt carries the same\n" +
+		"  Idempotency-Key header, so a retry never sends the email twice.\n" +
+		"\n" +
+		"Templates:\n" +
+		"  The subject and the block contents, including code blocks, are Go\n" +
+		"  text/template templates. Stdin and the output of run are not. Use\n" +
+		"  --no-templates to send a literal \"{{\":\n" +
+		"    {{.host}}                       variable set with --var or --vars-file\n" +
+		"    {{env \"JOB_NAME\"}}              environment variable\n" +
+		"    {{now | date \"2006-01-02\"}}     current date in the given layout\n" +
+		"    $ mendsail send --var host=$(hostname) --subject \"Backup failed on {{.host}}\" ...\n" +
+		"\n" +
+		"Message files:\n" +
+		"  --file uses the same vocabulary as the flags above. Flags and MENDSAIL_*\n" +
+		"  variables take precedence over the file, and blocks from flags are added\n" +
+		"  after the blocks from the file. The file is YAML or JSON; YAML anchors, tags, flow\n" +
+		"  mappings and multiple documents are not supported. Example:\n" +
+		"    to: [oncall@example.com]\n" +
+		"    subject: Nightly backup failed\n" +
+		"    blocks:\n" +
+		"      - alert: Disk full\n" +
+		"        style: danger\n" +
+		"      - list: [host-1, host-2]\n" +
+		"\n" +
+		"Exit codes:\n" +
+		"  0  Success (or the email was queued with --spool-on-failure)\n" +
+		"  1  Unexpected error\n" +
+		"  2  Usage error: unknown subcommand or option, missing value\n" +
+		"  3  Validation error: invalid options or files, or the API rejected the email\n" +
+		"  4  Authentication failed (HTTP 401 or 403)\n" +
+		"  5  Rate limited (HTTP 429), try again later\n" +
+		"  6  Server error (HTTP 5xx), try again later\n" +
+		"  7  Network error, try again later\n" +
+		"  8  Timeout, try again later\n" +
+		"  run exits with the command's own status if the command fails, even if the\n" +
+		"  email can't be sent either.\n" +
+		"\n" +
+		"Configuration:\n" +
+		"  Profiles are read from $XDG_CONFIG_HOME/mendsail/config.toml\n" +
+		"  (~/.config/mendsail/config.toml by default) and selected with --profile,\n" +
+		"  MENDSAIL_PROFILE or default-profile. A profile may set api-key, base-url,\n" +
+		"  to, cc, bcc, reply-to, subject, subject-prefix, attach-max-bytes and the\n" +
+		"  stdin-* options. The file is TOML, except for inline tables, arrays of\n" +
+		"  tables and dates:\n" +
+		"    default-profile = \"production\"\n" +
+		"    [profiles.production]\n" +
+		"    api-key = \"...\"\n" +
+		"    to = [\"oncall@example.com\"]\n" +
+		"    subject-prefix = \"[prod] \"\n" +
+		"  Each setting is taken from the first of: command line flag, environment\n" +
+		"  variable, --file message file, profile, built-in default.\n" +
+		"\n" +
+		"  \"config set\" and \"config unset\" edit the selected profile (or the one given\n" +
+		"  with --profile), keeping comments intact. \"config explain\" shows the value\n" +
+		"  every setting would have with the given options and where it comes from.\n" +
+		"  \"config get api-key\" and \"config explain\" mask the API key, add --show-secret\n" +
+		"  to \"config get\" to print it as is:\n" +
+		"    $ mendsail config set --profile staging to oncall@example.com\n" +
+		"    $ mendsail config explain --profile staging --subject Test\n" +
+		"\n" +
+		"Credentials:\n" +
+		"  \"mendsail login\" asks for the API key (or reads it from --api-key-file or\n" +
+		"  --api-key-stdin) and stores it for the selected profile in the Secret\n" +
+		"  Service using secret-tool, or in ~/.config/mendsail/credentials.toml (mode\n" +
+		"  0600) if that's not available. The stored key is used when no flag,\n" +
+		"  environment variable or profile sets one. \"mendsail logout\" removes it.\n" +
+		"    $ mendsail login --profile production\n" +
+		"\n" +
+		"Supported environment variables:\n" +
+		"  MENDSAIL_PROFILE MENDSAIL_API_KEY MENDSAIL_BASE_URL MENDSAIL_TO MENDSAIL_CC\n" +
+		"  MENDSAIL_BCC MENDSAIL_REPLY_TO MENDSAIL_SUBJECT MENDSAIL_SUBJECT_PREFIX\n" +
+		"  MENDSAIL_TO, MENDSAIL_CC and MENDSAIL_BCC accept comma-separated lists.\n" +
+		"\n" +
+		"Completion:\n" +
+		"  \"mendsail completion <shell>\" prints a completion script for bash, zsh or\n" +
+		"  fish. It completes commands, options, values such as style:danger and the\n" +
+		"  profile names from the config file:\n" +
+		"    $ eval \"$(mendsail completion bash)\"    # in ~/.bashrc\n" +
+		"    $ eval \"$(mendsail completion zsh)\"     # in ~/.zshrc\n" +
+		"    $ mendsail completion fish | source     # in ~/.config/fish/config.fish\n" +
+		"\n" +
+		"Links:\n" +
+		"  - Documentation:     https://mendsail.com/docs\n" +
+		"  - Source code:       https://github.com/codeclown/mendsail-cli\n" +
+		"")
+	return errors.New(builder.String())
+}
+
+type runCommandType func(args []string) error
+
+// Returned by a command to make mendsail exit with a specific status code.
+// An exitCodeError with a nil err exits silently.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return ""
+	}
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+func runMain(args []string, showHelpFn showHelpType, commands map[string]runCommandType) error {
+	if len(args) < 1 {
+		return usageError(showHelpFn())
+	}
+	if args[0] == "--help" || args[0] == "-h" || args[0] == "help" {
+		// Asked for, so it's not an error.
+		return &exitCodeError{code: 0, err: showHelpFn()}
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		return usageError(showHelpFn())
+	}
+	return command(args[1:])
+}
+
+func main() {
+	commands := map[string]runCommandType{
+		"send":       runSend,
+		"run":        runRun,
+		"queue":      runQueue,
+		"config":     runConfig,
+		"login":      runLogin,
+		"logout":     runLogout,
+		"completion": runCompletion,
+	}
+	err := runMain(os.Args[1:], showHelp, commands)
+
+	if err != nil {
+		if err.Error() != "" {
+			fmt.Println(err)
+		}
+		os.Exit(exitCodeFor(err))
+	}
+
+	os.Exit(0)
+}
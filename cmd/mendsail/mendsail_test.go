@@ -14,6 +14,10 @@ func dummyRunSend(args []string) error {
 	return nil
 }
 
+var dummyCommands = map[string]runCommandType{
+	"send": dummyRunSend,
+}
+
 func Test_runMain_NoArgs(t *testing.T) {
 	var calledTimes int
 	mockShowHelp := func() error {
@@ -21,8 +25,9 @@ func Test_runMain_NoArgs(t *testing.T) {
 		return errors.New("mocked help")
 	}
 	args := []string{}
-	err := runMain(args, mockShowHelp, dummyRunSend)
+	err := runMain(args, mockShowHelp, dummyCommands)
 	expectError(t, "mocked help", err)
+	expectExitCode(t, 2, err)
 	if calledTimes != 1 {
 		t.Errorf("calledWith: expected=%d actual=%d", 1, calledTimes)
 	}
@@ -35,8 +40,9 @@ func Test_runMain_UnknownCommand(t *testing.T) {
 		return errors.New("mocked help")
 	}
 	args := []string{"foobar"}
-	err := runMain(args, mockShowHelp, dummyRunSend)
+	err := runMain(args, mockShowHelp, dummyCommands)
 	expectError(t, "mocked help", err)
+	expectExitCode(t, 2, err)
 	if calledTimes != 1 {
 		t.Errorf("calledWith: expected=%d actual=%d", 1, calledTimes)
 	}
@@ -49,12 +55,14 @@ func Test_runMain_HelpOption(t *testing.T) {
 		return errors.New("mocked help")
 	}
 	args := []string{"--help"}
-	err := runMain(args, mockShowHelp, dummyRunSend)
+	err := runMain(args, mockShowHelp, dummyCommands)
 	expectError(t, "mocked help", err)
+	expectExitCode(t, 0, err)
 	if calledTimes != 1 {
 		t.Errorf("calledWith: expected=%d actual=%d", 1, calledTimes)
 	}
 }
+
 func Test_runMain_CallsRunSend(t *testing.T) {
 	var calledWith []string
 	mockRunSend := func(args []string) error {
@@ -63,7 +71,26 @@ func Test_runMain_CallsRunSend(t *testing.T) {
 	}
 	args := []string{"send", "--to", "foobar@example.com"}
 	expectedCalledWith := []string{"--to", "foobar@example.com"}
-	err := runMain(args, dummyShowHelp, mockRunSend)
+	err := runMain(args, dummyShowHelp, map[string]runCommandType{"send": mockRunSend})
+	expectError(t, "mocked error", err)
+	if !reflect.DeepEqual(expectedCalledWith, calledWith) {
+		t.Errorf("calledWith: expected=%s actual=%s", expectedCalledWith, calledWith)
+	}
+}
+
+func Test_runMain_CallsRunRun(t *testing.T) {
+	var calledWith []string
+	mockRunRun := func(args []string) error {
+		calledWith = args
+		return errors.New("mocked error")
+	}
+	commands := map[string]runCommandType{
+		"send": dummyRunSend,
+		"run":  mockRunRun,
+	}
+	args := []string{"run", "--to", "foobar@example.com", "--", "true"}
+	expectedCalledWith := []string{"--to", "foobar@example.com", "--", "true"}
+	err := runMain(args, dummyShowHelp, commands)
 	expectError(t, "mocked error", err)
 	if !reflect.DeepEqual(expectedCalledWith, calledWith) {
 		t.Errorf("calledWith: expected=%s actual=%s", expectedCalledWith, calledWith)
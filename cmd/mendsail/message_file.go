@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Message files describe an email with the same vocabulary as the command
+// line flags, e.g.:
+//
+//   to: [oncall@example.com, manager@example.com]
+//   subject: Nightly backup failed
+//   blocks:
+//     - heading: Backup failed
+//     - alert: Disk full
+//       style: danger
+//     - list: [host-1, host-2]
+//     - button: https://example.com/runbook
+//       text: Open runbook
+//     - kv:
+//         host: web-1
+//         job: backup
+//     - divider:
+//     - table: [Host, Disk]
+//       rows:
+//         - [web-1, 91%]
+//         - [db-1, 45%]
+
+type messageFileBlockSpec struct {
+	blockType string
+	options   []string
+}
+
+var messageFileBlocks = map[string]messageFileBlockSpec{
+	"heading":    {mendsail.BlockTypeHeading, nil},
+	"paragraph":  {mendsail.BlockTypeParagraph, nil},
+	"code-block": {mendsail.BlockTypeCodeBlock, nil},
+	"list":       {mendsail.BlockTypeList, nil},
+	"image":      {mendsail.BlockTypeImage, []string{"alt", "width"}},
+	"alert":      {mendsail.BlockTypeAlert, []string{"style"}},
+	"link":       {mendsail.BlockTypeLink, []string{"text"}},
+	"button":     {mendsail.BlockTypeButton, []string{"text", "style", "ghost"}},
+	"table":      {mendsail.BlockTypeTable, []string{"rows"}},
+	"kv":         {mendsail.BlockTypeKeyValue, nil},
+	"divider":    {mendsail.BlockTypeDivider, nil},
+	"quote":      {mendsail.BlockTypeQuote, []string{"cite"}},
+	"spacer":     {mendsail.BlockTypeSpacer, []string{"size"}},
+}
+
+func loadMessageFile(path string) (*sendOptions, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseDocument(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return messageDocumentToSendOptions(path, root)
+}
+
+func messageDocumentToSendOptions(fileName string, root *docNode) (*sendOptions, error) {
+	fail := func(node *docNode, format string, args ...interface{}) error {
+		return &docError{file: fileName, line: node.line, msg: fmt.Sprintf(format, args...)}
+	}
+
+	if root.kind != docMap {
+		return nil, fail(root, "expected a mapping at the top level")
+	}
+
+	options := sendOptions{fileLines: make(map[string]int)}
+	for _, key := range root.keys {
+		value := root.fields[key]
+		var err error
+		switch key {
+		case "to":
+			options.to, err = docStringList(value)
+		case "cc":
+			options.cc, err = docStringList(value)
+		case "bcc":
+			options.bcc, err = docStringList(value)
+		case "reply-to":
+			options.replyTo, err = docString(value)
+		case "subject":
+			options.subject, err = docString(value)
+		case "blocks":
+			if value.kind != docList {
+				return nil, fail(value, "'blocks' should be a list")
+			}
+			for index, item := range value.items {
+				block, blockErr := docToSendBlock(item)
+				if blockErr != nil {
+					return nil, &docError{file: fileName, line: blockErr.line, msg: fmt.Sprintf("blocks[%d]: %s", index, blockErr.msg)}
+				}
+				options.blocks = append(options.blocks, block)
+			}
+		default:
+			return nil, fail(value, "unknown key '%s'", key)
+		}
+		if err != nil {
+			return nil, fail(value, "'%s' %s", key, err)
+		}
+		if key != "blocks" {
+			options.fileLines[key] = value.line
+		}
+	}
+	return &options, nil
+}
+
+// docToSendBlock converts a list item of blocks. Errors point at the line of
+// the offending field.
+func docToSendBlock(node *docNode) (sendBlock, *docError) {
+	block := sendBlock{}
+	if node.kind != docMap {
+		return block, docErrorf(node.line, "expected a mapping such as 'paragraph: text'")
+	}
+
+	blockKey := ""
+	for _, key := range node.keys {
+		if _, ok := messageFileBlocks[key]; ok {
+			if blockKey != "" {
+				return block, docErrorf(node.fields[key].line, "found both '%s' and '%s', use one block type per list item", blockKey, key)
+			}
+			blockKey = key
+		}
+	}
+	if blockKey == "" {
+		return block, docErrorf(node.line, "missing block type (one of: %s)", strings.Join(messageFileBlockKeys(), ", "))
+	}
+	spec := messageFileBlocks[blockKey]
+	block.blockType = spec.blockType
+
+	var err error
+	value := node.fields[blockKey]
+	switch spec.blockType {
+	case mendsail.BlockTypeList:
+		block.items, err = docStringList(value)
+	case mendsail.BlockTypeTable:
+		block.headers, err = docStringList(value)
+	case mendsail.BlockTypeKeyValue:
+		if value.kind != docMap {
+			return block, docErrorf(value.line, "'%s' should be a mapping of keys to values", blockKey)
+		}
+		block.pairs, err = docToPairs(value)
+	case mendsail.BlockTypeImage, mendsail.BlockTypeLink, mendsail.BlockTypeButton:
+		block.url, err = docString(value)
+	case mendsail.BlockTypeDivider, mendsail.BlockTypeSpacer:
+		// Written as "- divider:", the value is ignored.
+		_, err = docString(value)
+	default:
+		block.text, err = docString(value)
+	}
+	if err != nil {
+		return block, docErrorf(value.line, "'%s' %s", blockKey, err)
+	}
+
+	for _, key := range node.keys {
+		if key == blockKey {
+			continue
+		}
+		field := node.fields[key]
+		if !containsString(spec.options, key) {
+			return block, docErrorf(field.line, "unknown option '%s' for '%s'", key, blockKey)
+		}
+		if key == "rows" {
+			block.rows, err = docTableRows(field, block.headers)
+			if err != nil {
+				return block, docErrorf(field.line, "'%s' %s", key, err)
+			}
+			continue
+		}
+		optionValue, err := docString(field)
+		if err != nil {
+			return block, docErrorf(field.line, "'%s' %s", key, err)
+		}
+		switch key {
+		case "alt":
+			block.alt = optionValue
+		case "width":
+			width, conversionErr := strconv.Atoi(optionValue)
+			if conversionErr != nil {
+				return block, docErrorf(field.line, "could not parse width as an integer")
+			}
+			block.width = width
+		case "style":
+			if err := validateStyle(optionValue); err != nil {
+				return block, docErrorf(field.line, "%s", err)
+			}
+			block.style = optionValue
+		case "ghost":
+			block.ghost = optionValue != "" && optionValue != "false"
+		case "text":
+			block.text = optionValue
+		case "cite":
+			block.cite = optionValue
+		case "size":
+			if err := validateSize(optionValue); err != nil {
+				return block, docErrorf(field.line, "%s", err)
+			}
+			block.size = optionValue
+		}
+	}
+	if spec.blockType == mendsail.BlockTypeButton && block.text == "" {
+		return block, docErrorf(node.line, "missing button text")
+	}
+	if spec.blockType == mendsail.BlockTypeLink && block.text == "" {
+		block.text = block.url
+	}
+	return block, nil
+}
+
+func messageFileBlockKeys() []string {
+	// Same order as the blocks in --help.
+	return []string{"alert", "button", "code-block", "divider", "heading", "image", "kv", "link", "list", "paragraph", "quote", "spacer", "table"}
+}
+
+func docString(node *docNode) (string, error) {
+	if node.kind != docScalar {
+		return "", fmt.Errorf("should be a single value")
+	}
+	return node.value, nil
+}
+
+func docStringList(node *docNode) ([]string, error) {
+	if node.kind == docScalar {
+		return []string{node.value}, nil
+	}
+	if node.kind != docList {
+		return nil, fmt.Errorf("should be a value or a list of values")
+	}
+	values := make([]string, 0)
+	for _, item := range node.items {
+		if item.kind != docScalar {
+			return nil, fmt.Errorf("should be a list of values")
+		}
+		values = append(values, item.value)
+	}
+	return values, nil
+}
+
+// docTableRows reads a list of rows, each a list with one value per header.
+func docTableRows(node *docNode, headers []string) ([][]string, error) {
+	if node.kind != docList {
+		return nil, fmt.Errorf("should be a list of rows")
+	}
+	rows := make([][]string, 0, len(node.items))
+	for i, item := range node.items {
+		if item.kind != docList {
+			return nil, fmt.Errorf("row %d should be a list of values", i+1)
+		}
+		row, err := docStringList(item)
+		if err != nil {
+			return nil, fmt.Errorf("row %d %s", i+1, err)
+		}
+		if err := validateTableRow(headers, row); err != nil {
+			return nil, fmt.Errorf("row %d: %s", i+1, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeMessageFile adds the blocks of the message file before the blocks from
+// flags. Its settings (to, subject, ...) are kept aside in options.fileValues,
+// as they rank below flags and environment variables, see resolveSetting.
+func mergeMessageFile(options *sendOptions, fromFile *sendOptions) {
+	options.fileValues = fromFile.flagValues()
+	options.fileLines = fromFile.fileLines
+	options.blocks = append(fromFile.blocks, options.blocks...)
+}
+
+func applyMessageFile(options *sendOptions) error {
+	if options.file == "" {
+		return nil
+	}
+	fromFile, err := loadMessageFile(options.file)
+	if err != nil {
+		return err
+	}
+	mergeMessageFile(options, fromFile)
+	return nil
+}
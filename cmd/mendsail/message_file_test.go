@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func parseMessageFileString(fileName string, data string) (*sendOptions, error) {
+	root, err := parseDocument(fileName, []byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return messageDocumentToSendOptions(fileName, root)
+}
+
+func Test_messageDocumentToSendOptions_AllBlocks(t *testing.T) {
+	data := "to: foobar@example.com\n" +
+		"cc: [manager@example.com]\n" +
+		"reply-to: tickets@example.com\n" +
+		"subject: example 123\n" +
+		"blocks:\n" +
+		"  - heading: heading 1\n" +
+		"  - paragraph: paragraph 1\n" +
+		"  - code-block: code 1\n" +
+		"  - list: [item 1, item 2]\n" +
+		"  - image: https://example.com/image.png\n" +
+		"    alt: Alt text\n" +
+		"    width: 123\n" +
+		"  - alert: alert 1\n" +
+		"    style: danger\n" +
+		"  - link: https://example.com\n" +
+		"  - button: https://example.com\n" +
+		"    text: lorem ipsum\n" +
+		"    style: warning\n" +
+		"    ghost: true\n" +
+		"  - table: [Host, Disk]\n" +
+		"    rows:\n" +
+		"      - [web-1, 91%]\n" +
+		"      - [db-1, 45%]\n" +
+		"  - kv:\n" +
+		"      host: web-1\n" +
+		"      job: backup\n" +
+		"  - divider:\n" +
+		"  - quote: Ship it\n" +
+		"    cite: Release notes\n" +
+		"  - spacer:\n" +
+		"    size: small\n"
+	expected := sendOptions{
+		to:      []string{"foobar@example.com"},
+		cc:      []string{"manager@example.com"},
+		replyTo: "tickets@example.com",
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "heading 1"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 1"},
+			sendBlock{blockType: "CodeBlock", text: "code 1"},
+			sendBlock{blockType: "List", items: []string{"item 1", "item 2"}},
+			sendBlock{blockType: "Image", url: "https://example.com/image.png", alt: "Alt text", width: 123},
+			sendBlock{blockType: "Alert", text: "alert 1", style: "danger"},
+			sendBlock{blockType: "Link", url: "https://example.com", text: "https://example.com"},
+			sendBlock{blockType: "Button", url: "https://example.com", text: "lorem ipsum", style: "warning", ghost: true},
+			sendBlock{blockType: "Table", headers: []string{"Host", "Disk"}, rows: [][]string{{"web-1", "91%"}, {"db-1", "45%"}}},
+			sendBlock{blockType: "KeyValue", pairs: []mendsail.Pair{{Key: "host", Value: "web-1"}, {Key: "job", Value: "backup"}}},
+			sendBlock{blockType: "Divider"},
+			sendBlock{blockType: "Quote", text: "Ship it", cite: "Release notes"},
+			sendBlock{blockType: "Spacer", size: "small"},
+		},
+	}
+	actual, err := parseMessageFileString("message.yaml", data)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_messageDocumentToSendOptions_Json(t *testing.T) {
+	data := "{\"to\": [\"foobar@example.com\"], \"blocks\": [{\"list\": [\"item 1\"]}]}"
+	expected := sendOptions{
+		to: []string{"foobar@example.com"},
+		blocks: []sendBlock{
+			sendBlock{blockType: "List", items: []string{"item 1"}},
+		},
+	}
+	actual, err := parseMessageFileString("message.json", data)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_messageDocumentToSendOptions_Errors(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"foobar: 1\n", "message.yaml:1: unknown key 'foobar'"},
+		{"subject: [a, b]\n", "message.yaml:1: 'subject' should be a single value"},
+		{"blocks: foobar\n", "message.yaml:1: 'blocks' should be a list"},
+		{
+			"blocks:\n  - heading: foo\n  - foobar: bar\n",
+			"message.yaml:3: blocks[1]: missing block type (one of: alert, button, code-block, divider, heading, image, kv, link, list, paragraph, quote, spacer, table)",
+		},
+		{
+			"blocks:\n  - heading: foo\n    paragraph: bar\n",
+			"message.yaml:3: blocks[0]: found both 'heading' and 'paragraph', use one block type per list item",
+		},
+		{
+			"blocks:\n  - alert: foo\n    style: foobar\n",
+			"message.yaml:3: blocks[0]: invalid style: 'foobar' (should be one of: success, warning, danger, info)",
+		},
+		{
+			"blocks:\n  - image: foo.png\n    style: danger\n",
+			"message.yaml:3: blocks[0]: unknown option 'style' for 'image'",
+		},
+		{
+			"blocks:\n  - image: foo.png\n    width: wide\n",
+			"message.yaml:3: blocks[0]: could not parse width as an integer",
+		},
+		{
+			"blocks:\n  - button: https://example.com\n",
+			"message.yaml:2: blocks[0]: missing button text",
+		},
+		{
+			"blocks:\n  - table: [Host, Disk]\n    rows:\n      - [web-1]\n",
+			"message.yaml:3: blocks[0]: 'rows' row 1: table row has 1 columns, expected 2 (one per header)",
+		},
+		{
+			"blocks:\n  - kv: web-1\n",
+			"message.yaml:2: blocks[0]: 'kv' should be a mapping of keys to values",
+		},
+		{
+			"blocks:\n  - spacer:\n    size: huge\n",
+			"message.yaml:3: blocks[0]: invalid size: 'huge' (should be one of: small, medium, large)",
+		},
+	}
+	for _, test := range tests {
+		_, err := parseMessageFileString("message.yaml", test.data)
+		expectError(t, test.expected, err)
+	}
+}
+
+func Test_applyMessageFile_Precedence(t *testing.T) {
+	writeConfig(t, testConfig)
+	t.Setenv("MENDSAIL_PROFILE", "")
+	t.Setenv("MENDSAIL_API_KEY", "")
+	t.Setenv("MENDSAIL_TO", "env@example.com")
+	t.Setenv("MENDSAIL_CC", "")
+	t.Setenv("MENDSAIL_REPLY_TO", "")
+	t.Setenv("MENDSAIL_SUBJECT", "")
+	t.Setenv("MENDSAIL_SUBJECT_PREFIX", "")
+	path := filepath.Join(t.TempDir(), "message.yaml")
+	expectNoError(t, ioutil.WriteFile(path, []byte("to: file@example.com\n"+
+		"cc: file@example.com\n"+
+		"subject: from file\n"+
+		"blocks:\n"+
+		"  - heading: from file\n"), 0644))
+
+	options, err := parseSendArgs([]string{"--file", path, "--subject", "from flags", "--paragraph", "from flags"})
+	expectNoError(t, err)
+	expectNoError(t, applyMessageFile(options))
+	expectNoError(t, applySettings(options))
+	expected := sendOptions{
+		to:      []string{"env@example.com"},
+		cc:      []string{"file@example.com"},
+		subject: "from flags",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "from file"},
+			sendBlock{blockType: "Paragraph", text: "from flags"},
+		},
+	}
+	exceptOptions(t, expected, options, nil)
+
+	options, err = parseSendArgs([]string{"--file", path})
+	expectNoError(t, err)
+	expectNoError(t, applyMessageFile(options))
+	resolved, err := loadSettings(options)
+	expectNoError(t, err)
+	for _, r := range resolved {
+		if r.key == "subject" {
+			exceptStringsEqual(t, "file "+path+":3", r.source)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+const userAgent = "mendsail-cli/1.0"
+
+// newApiClient creates a client for the API at baseUrl. A zero timeout means
+// the default.
+func newApiClient(baseUrl string, apiKey string, timeout time.Duration, retry mendsail.RetryPolicy) *mendsail.Client {
+	if timeout == 0 {
+		timeout = mendsail.DefaultTimeout
+	}
+	return mendsail.NewClient(
+		mendsail.WithBaseUrl(baseUrl),
+		mendsail.WithApiKey(apiKey),
+		mendsail.WithUserAgent(userAgent),
+		mendsail.WithHttpClient(&http.Client{Timeout: timeout}),
+		mendsail.WithRetryPolicy(retry),
+	)
+}
+
+// writeDryRun prints req, which is what Send would post, with the API key
+// masked.
+func writeDryRun(w io.Writer, req *http.Request) error {
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	if err := json.Indent(&body, payload, "", "  "); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%s %s\n", req.Method, req.URL)
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if strings.EqualFold(name, "x-api-key") {
+			value = maskSecret(value)
+		}
+		fmt.Fprintf(w, "%s: %s\n", strings.ToLower(name), value)
+	}
+	fmt.Fprintf(w, "\n%s\n", body.String())
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func Test_sendOptions_retryPolicy(t *testing.T) {
+	options, err := parseSendArgs([]string{"--timeout", "5s", "--retry-max-wait", "1m"})
+	expectNoError(t, err)
+	actual := options.retryPolicy()
+	expected := mendsail.RetryPolicy{Retries: 3, MaxWait: time.Minute}
+	if actual != expected || options.timeout != 5*time.Second {
+		t.Errorf("retryPolicy: expected=%v actual=%v", expected, actual)
+	}
+
+	options, err = parseSendArgs([]string{"--retries", "0"})
+	expectNoError(t, err)
+	actual = options.retryPolicy()
+	expected = mendsail.RetryPolicy{Retries: 0, MaxWait: 30 * time.Second}
+	if actual != expected {
+		t.Errorf("retryPolicy: expected=%v actual=%v", expected, actual)
+	}
+
+	_, err = parseSendArgs([]string{"--timeout", "soon"})
+	expectError(t, "invalid value for --timeout: 'soon' (should be a duration such as 30s or 1m) (argument 1)", err)
+}
+
+func Test_writeDryRun(t *testing.T) {
+	client := newApiClient("", "abcd-efgh-1234", 0, mendsail.DefaultRetryPolicy)
+	message := &mendsail.Message{To: []string{"foo@example.com"}, Subject: "Test"}
+	req, err := client.NewRequest(context.Background(), message, "key-1")
+	expectNoError(t, err)
+	var out bytes.Buffer
+	expectNoError(t, writeDryRun(&out, req))
+	expected := "POST https://api.mendsail.com/v1/emails\n" +
+		"content-type: application/json\n" +
+		"idempotency-key: key-1\n" +
+		"user-agent: mendsail-cli/1.0\n" +
+		"x-api-key: **********1234\n" +
+		"\n" +
+		"{\n" +
+		"  \"to\": [\n" +
+		"    \"foo@example.com\"\n" +
+		"  ],\n" +
+		"  \"subject\": \"Test\",\n" +
+		"  \"blocks\": null\n" +
+		"}\n"
+	exceptStringsEqual(t, expected, out.String())
+}
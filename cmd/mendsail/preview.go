@@ -0,0 +1,203 @@
+package main
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// --preview renders the payload locally, roughly the way recipients will see
+// it, instead of sending it.
+
+const (
+	PreviewHtml = "html"
+	PreviewText = "text"
+)
+
+//go:embed preview.html.tmpl
+var previewHtmlTemplate string
+
+// Colors for alert and button styles. Unstyled buttons use the primary color
+// and unstyled alerts look like info.
+var previewStyleColors = map[string][2]string{
+	"":        {"#111827", "#f3f4f6"},
+	"success": {"#16a34a", "#f0fdf4"},
+	"warning": {"#d97706", "#fffbeb"},
+	"danger":  {"#dc2626", "#fef2f2"},
+	"info":    {"#2563eb", "#eff6ff"},
+}
+
+func validatePreview(value string) error {
+	if value == PreviewHtml || value == PreviewText {
+		return nil
+	}
+	return errors.New("invalid value for --preview: '" + value + "' (should be html or text)")
+}
+
+// Heights of spacers. Spacers without a size are medium.
+var previewSpacerHeights = map[string]string{
+	"":                  "24px",
+	mendsail.SizeSmall:  "12px",
+	mendsail.SizeMedium: "24px",
+	mendsail.SizeLarge:  "48px",
+}
+
+var previewTemplate = template.Must(template.New("preview").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"spacerHeight": func(size string) template.CSS {
+		return template.CSS(previewSpacerHeights[size])
+	},
+	"styleColor": func(style string) template.CSS {
+		return template.CSS(previewStyleColors[style][0])
+	},
+	"styleBackground": func(style string) template.CSS {
+		return template.CSS(previewStyleColors[style][1])
+	},
+}).Parse(previewHtmlTemplate))
+
+type previewData struct {
+	*mendsail.Message
+	// Data URLs of the inline attachments by their "cid:" URL, since a browser
+	// can't resolve those.
+	InlineImages map[string]template.URL
+}
+
+func renderPreviewHtml(payload *mendsail.Message, w io.Writer) error {
+	data := previewData{payload, make(map[string]template.URL)}
+	for _, attachment := range payload.Attachments {
+		if attachment.ContentId != "" {
+			data.InlineImages[mendsail.ContentUrl(attachment.ContentId)] = template.URL("data:" + attachment.ContentType + ";base64," + attachment.Content)
+		}
+	}
+	return previewTemplate.Execute(w, data)
+}
+
+// inlineAttachment returns the inline attachment that url refers to, if any.
+func inlineAttachment(payload *mendsail.Message, url string) (mendsail.Attachment, bool) {
+	for _, attachment := range payload.Attachments {
+		if attachment.ContentId != "" && mendsail.ContentUrl(attachment.ContentId) == url {
+			return attachment, true
+		}
+	}
+	return mendsail.Attachment{}, false
+}
+
+// renderPreviewText renders the plain-text version, with one paragraph per
+// block.
+func renderPreviewText(payload *mendsail.Message, w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString("Subject: " + payload.Subject + "\n")
+	builder.WriteString("To: " + strings.Join(payload.To, ", ") + "\n")
+	if len(payload.Cc) > 0 {
+		builder.WriteString("Cc: " + strings.Join(payload.Cc, ", ") + "\n")
+	}
+	if len(payload.Bcc) > 0 {
+		builder.WriteString("Bcc: " + strings.Join(payload.Bcc, ", ") + "\n")
+	}
+	if payload.ReplyTo != "" {
+		builder.WriteString("Reply-To: " + payload.ReplyTo + "\n")
+	}
+	if len(payload.Attachments) > 0 {
+		names := make([]string, len(payload.Attachments))
+		for i, attachment := range payload.Attachments {
+			names[i] = attachment.Filename + " (" + attachment.ContentType + ")"
+			if attachment.ContentId != "" {
+				names[i] = attachment.Filename + " (" + attachment.ContentType + ", inline)"
+			}
+		}
+		builder.WriteString("Attachments: " + strings.Join(names, ", ") + "\n")
+	}
+
+	for _, block := range payload.Blocks {
+		builder.WriteString("\n")
+		switch block.BlockType {
+		case mendsail.BlockTypeHeading:
+			builder.WriteString(block.Text + "\n")
+			builder.WriteString(strings.Repeat("=", len([]rune(block.Text))) + "\n")
+		case mendsail.BlockTypeList:
+			for _, item := range block.Items {
+				builder.WriteString("- " + item + "\n")
+			}
+		case mendsail.BlockTypeImage:
+			alt := block.Alt
+			if alt == "" {
+				alt = "Image"
+			}
+			if attachment, ok := inlineAttachment(payload, block.Url); ok {
+				builder.WriteString("[" + alt + "] " + attachment.Filename + " (inline)\n")
+			} else {
+				builder.WriteString("[" + alt + "] " + block.Url + "\n")
+			}
+		case mendsail.BlockTypeCodeBlock:
+			for _, line := range strings.Split(strings.TrimRight(block.Text, "\n"), "\n") {
+				builder.WriteString(strings.TrimRight("    "+line, " ") + "\n")
+			}
+		case mendsail.BlockTypeAlert:
+			label := strings.ToUpper(block.Style)
+			if label == "" {
+				label = "NOTE"
+			}
+			builder.WriteString("[" + label + "] " + block.Text + "\n")
+		case mendsail.BlockTypeLink:
+			if block.Text == "" || block.Text == block.Url {
+				builder.WriteString(block.Url + "\n")
+			} else {
+				builder.WriteString(block.Text + " <" + block.Url + ">\n")
+			}
+		case mendsail.BlockTypeButton:
+			builder.WriteString(block.Text + ": " + block.Url + "\n")
+		case mendsail.BlockTypeTable:
+			builder.WriteString(renderTableText(block.Headers, block.Rows))
+		case mendsail.BlockTypeDivider:
+			builder.WriteString(strings.Repeat("-", 40) + "\n")
+		case mendsail.BlockTypeQuote:
+			for _, line := range strings.Split(block.Text, "\n") {
+				builder.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+			if block.Cite != "" {
+				builder.WriteString("> — " + block.Cite + "\n")
+			}
+		case mendsail.BlockTypeSpacer:
+			// The blank line between blocks is enough.
+		case mendsail.BlockTypeKeyValue:
+			for _, pair := range block.Pairs {
+				builder.WriteString(pair.Key + ": " + pair.Value + "\n")
+			}
+		default:
+			builder.WriteString(block.Text + "\n")
+		}
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// writePreview renders payload in the given format to path, or to stdout if
+// path is empty or "-".
+func writePreview(payload *mendsail.Message, format string, path string) error {
+	render := renderPreviewHtml
+	if format == PreviewText {
+		render = renderPreviewText
+	}
+	if path == "" || path == "-" {
+		return render(payload, os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(payload, file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Preview written to "+path+".")
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func previewTestPayload() *mendsail.Message {
+	return &mendsail.Message{
+		To:      []string{"foo@example.com", "bar@example.com"},
+		Cc:      []string{"cc@example.com"},
+		Subject: "Backup <failed>",
+		Blocks: []mendsail.Block{
+			mendsail.Heading("Backup failed"),
+			mendsail.Paragraph("Details below."),
+			mendsail.List("host-1", "host-2"),
+			mendsail.Image("https://example.com/graph.png", "Graph", 300),
+			mendsail.CodeBlock("line 1\n\nline 3\n"),
+			mendsail.Alert("Disk full", mendsail.StyleDanger),
+			mendsail.Link("https://example.com", "Dashboard"),
+			mendsail.Button("https://example.com/runbook", "Open runbook", mendsail.StyleWarning, true),
+			mendsail.Table([]string{"Host", "Disk"}, []string{"host-1", "91%"}),
+			mendsail.KeyValue(mendsail.Pair{Key: "Job", Value: "backup"}, mendsail.Pair{Key: "Exit status", Value: "1"}),
+			mendsail.Divider(),
+			mendsail.Quote("Ship it", "Release notes"),
+			mendsail.Spacer(mendsail.SizeLarge),
+		},
+	}
+}
+
+func Test_renderPreviewText(t *testing.T) {
+	var out bytes.Buffer
+	expectNoError(t, renderPreviewText(previewTestPayload(), &out))
+	expected := "Subject: Backup <failed>\n" +
+		"To: foo@example.com, bar@example.com\n" +
+		"Cc: cc@example.com\n" +
+		"\n" +
+		"Backup failed\n" +
+		"=============\n" +
+		"\n" +
+		"Details below.\n" +
+		"\n" +
+		"- host-1\n" +
+		"- host-2\n" +
+		"\n" +
+		"[Graph] https://example.com/graph.png\n" +
+		"\n" +
+		"    line 1\n" +
+		"\n" +
+		"    line 3\n" +
+		"\n" +
+		"[DANGER] Disk full\n" +
+		"\n" +
+		"Dashboard <https://example.com>\n" +
+		"\n" +
+		"Open runbook: https://example.com/runbook\n" +
+		"\n" +
+		"Host    Disk\n" +
+		"------  ----\n" +
+		"host-1  91%\n" +
+		"\n" +
+		"Job: backup\n" +
+		"Exit status: 1\n" +
+		"\n" +
+		"----------------------------------------\n" +
+		"\n" +
+		"> Ship it\n" +
+		"> — Release notes\n" +
+		"\n"
+	exceptStringsEqual(t, expected, out.String())
+}
+
+func Test_renderPreviewHtml(t *testing.T) {
+	var out bytes.Buffer
+	expectNoError(t, renderPreviewHtml(previewTestPayload(), &out))
+	html := out.String()
+	expectedSnippets := []string{
+		"<title>Backup &lt;failed&gt;</title>",
+		"<strong>To:</strong> foo@example.com, bar@example.com",
+		"<h1 style=\"margin: 0 0 16px; font-size: 22px; line-height: 1.3;\">Backup failed</h1>",
+		"<li>host-2</li>",
+		"width: 300px;\" width=\"300\"",
+		"<code>line 1\n\nline 3\n</code>",
+		"border-left: 4px solid #dc2626; background: #fef2f2;",
+		"<a href=\"https://example.com\" style=\"color: #2563eb;\">Dashboard</a>",
+		"color: #d97706; background: transparent;\">Open runbook</a>",
+		"text-align: left;\">Disk</th>",
+		"border-bottom: 1px solid #e5e7eb;\">91%</td>",
+		"font-weight: 600;\">Exit status</th><td style=\"padding: 2px 0;\">1</td>",
+		"<hr style=\"margin: 0 0 16px; border: 0; border-top: 1px solid #e5e7eb;\">",
+		"font-size: 13px;\">— Release notes</p>",
+		"<div style=\"height: 48px; line-height: 48px;\">&nbsp;</div>",
+	}
+	for _, snippet := range expectedSnippets {
+		if !strings.Contains(html, snippet) {
+			t.Errorf("expected preview to contain %q, got:\n%s", snippet, html)
+		}
+	}
+}
+
+func Test_parseSendArgs_Preview(t *testing.T) {
+	options, err := parseSendArgs([]string{"--preview-file", "mail.txt"})
+	expectNoError(t, err)
+	exceptStringsEqual(t, PreviewText, options.preview)
+	options, err = parseSendArgs([]string{"--preview-file", "mail.html"})
+	expectNoError(t, err)
+	exceptStringsEqual(t, PreviewHtml, options.preview)
+	options, err = parseSendArgs([]string{"--preview", "text", "--preview-file", "mail.html"})
+	expectNoError(t, err)
+	exceptStringsEqual(t, PreviewText, options.preview)
+	_, err = parseSendArgs([]string{"--preview", "pdf"})
+	expectError(t, "invalid value for --preview: 'pdf' (should be html or text) (argument 1)", err)
+}
+
+func Test_validateSendOptions_PreviewWithoutApiKey(t *testing.T) {
+	options := sendOptions{to: []string{"foo@example.com"}, subject: "Test", preview: PreviewHtml}
+	expectNoError(t, validateSendOptions(options))
+}
+
+func Test_renderPreview_InlineImage(t *testing.T) {
+	payload := &mendsail.Message{
+		To:          []string{"foo@example.com"},
+		Subject:     "Load",
+		Blocks:      []mendsail.Block{mendsail.Image(mendsail.ContentUrl("image1@mendsail"), "Load", 0)},
+		Attachments: []mendsail.Attachment{mendsail.NewInlineAttachment("graph.png", "image/png", "image1@mendsail", []byte("png"))},
+	}
+	var out bytes.Buffer
+	expectNoError(t, renderPreviewHtml(payload, &out))
+	if !strings.Contains(out.String(), "<img src=\"data:image/png;base64,cG5n\" alt=\"Load\"") {
+		t.Errorf("expected the inline image as a data URL, got:\n%s", out.String())
+	}
+	out.Reset()
+	expectNoError(t, renderPreviewText(payload, &out))
+	if !strings.Contains(out.String(), "Attachments: graph.png (image/png, inline)\n\n[Load] graph.png (inline)\n") {
+		t.Errorf("expected the inline image by its file name, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// With --output json, send reports its outcome as a single JSON object on
+// stdout, whether it succeeded or not.
+
+const (
+	SendStatusQueued = "queued"
+)
+
+type sendResult struct {
+	mendsail.Result
+	// Set when --spool-on-failure queued the email instead.
+	QueuedAs string `json:"queuedAs,omitempty"`
+}
+
+type errorResult struct {
+	Error errorResultDetails `json:"error"`
+}
+
+type errorResultDetails struct {
+	// One of the ErrorKind constants.
+	Type       string `json:"type"`
+	HttpStatus int    `json:"httpStatus,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message"`
+}
+
+func newErrorResult(err error) errorResult {
+	result := errorResult{Error: errorResultDetails{Type: errorKind(err), Message: err.Error()}}
+	var apiErr *mendsail.ApiError
+	if errors.As(err, &apiErr) {
+		result.Error.HttpStatus = apiErr.StatusCode
+		result.Error.Code = apiErr.Code
+		if apiErr.Message != "" {
+			result.Error.Message = apiErr.Message
+		}
+	}
+	return result
+}
+
+func writeJson(w io.Writer, value interface{}) error {
+	return json.NewEncoder(w).Encode(value)
+}
+
+// reportError prints err as JSON if that's the selected output, in which case
+// the returned error only carries the exit status.
+func (options *sendOptions) reportError(err error) error {
+	if err == nil || options.output != OutputJson {
+		return err
+	}
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) && exitErr.err == nil {
+		return err
+	}
+	if writeErr := writeJson(os.Stdout, newErrorResult(err)); writeErr != nil {
+		return err
+	}
+	return &exitCodeError{code: exitCodeFor(err)}
+}
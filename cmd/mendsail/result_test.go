@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func Test_sendResult(t *testing.T) {
+	result := sendResult{Result: mendsail.Result{Id: "msg_456", Recipients: []string{"foo@example.com"}, Attempts: 2}}
+	var out bytes.Buffer
+	expectNoError(t, writeJson(&out, result))
+	exceptStringsEqual(t, `{"id":"msg_456","status":"","recipients":["foo@example.com"],"attempts":2}`+"\n", out.String())
+
+	result.Status = SendStatusQueued
+	result.QueuedAs = "/tmp/spool/1.json"
+	out.Reset()
+	expectNoError(t, writeJson(&out, result))
+	exceptStringsEqual(t, `{"id":"msg_456","status":"queued","recipients":["foo@example.com"],"attempts":2,"queuedAs":"/tmp/spool/1.json"}`+"\n", out.String())
+}
+
+func Test_newErrorResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error":{"code":"invalid_recipient","message":"Recipient is not verified"}}`))
+	}))
+	defer server.Close()
+
+	err := testSend(server.URL, time.Second)
+	var out bytes.Buffer
+	expectNoError(t, writeJson(&out, newErrorResult(err)))
+	expected := `{"error":{"type":"validation","httpStatus":422,"code":"invalid_recipient","message":"Recipient is not verified"}}` + "\n"
+	exceptStringsEqual(t, expected, out.String())
+
+	out.Reset()
+	expectNoError(t, writeJson(&out, newErrorResult(errors.New("missing option: --to"))))
+	exceptStringsEqual(t, `{"error":{"type":"other","message":"missing option: --to"}}`+"\n", out.String())
+}
+
+func Test_reportError(t *testing.T) {
+	options := &sendOptions{}
+	err := errors.New("foobar")
+	if options.reportError(err) != err {
+		t.Errorf("expected the error to be returned as is with text output")
+	}
+	if options.reportError(nil) != nil {
+		t.Errorf("expected nil for nil")
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+type runOptions struct {
+	send          *sendOptions
+	command       []string
+	onFailureOnly bool
+	onSuccessOnly bool
+	tee           bool
+}
+
+type runResult struct {
+	command  []string
+	stdout   *stdinContent
+	stderr   *stdinContent
+	exitCode int
+	// Set if the command was killed by a signal.
+	signal    syscall.Signal
+	startErr  error
+	startedAt time.Time
+	duration  time.Duration
+	hostname  string
+}
+
+// Exit status used when the command could not be started at all, mirroring
+// what shells report for a missing executable.
+const runStartFailedExitCode = 127
+
+// Output is captured with the --stdin-* limits, per stream. Without them, the
+// last lines are kept, up to these defaults, so that a chatty command can't
+// use up all memory.
+const (
+	defaultRunOutputTail     = 200
+	defaultRunOutputMaxBytes = 64 * 1024
+)
+
+// Shells report a command killed by a signal with this plus the signal number.
+const runSignaledExitCodeBase = 128
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+func signalName(signal syscall.Signal) string {
+	if name, ok := signalNames[signal]; ok {
+		return name
+	}
+	return "signal " + strconv.Itoa(int(signal))
+}
+
+// parseRunArgs splits args at the first "--" into the options and the
+// command. Unlike in send, "--" doesn't make the following arguments literal
+// values here, since the command itself may contain "--". A value that looks
+// like an option needs the --option=value form instead.
+func parseRunArgs(args []string) (*runOptions, error) {
+	separator := -1
+	for i, arg := range args {
+		if arg == "--" {
+			separator = i
+			break
+		}
+	}
+	if separator == -1 || separator == len(args)-1 {
+		return nil, errors.New("missing command, usage: mendsail run <options> -- <command> [args...]")
+	}
+
+	options := runOptions{
+		command: args[separator+1:],
+	}
+	sendArgs := make([]string, 0)
+	// Where each of sendArgs is in args, so that errors point at the right one.
+	sendArgIndexes := make([]int, 0)
+	for i, arg := range args[:separator] {
+		switch arg {
+		case "--on-failure-only":
+			options.onFailureOnly = true
+		case "--on-success-only":
+			options.onSuccessOnly = true
+		case "--tee":
+			options.tee = true
+		default:
+			sendArgs = append(sendArgs, arg)
+			sendArgIndexes = append(sendArgIndexes, i)
+		}
+	}
+	if options.onFailureOnly && options.onSuccessOnly {
+		return nil, errors.New("--on-failure-only and --on-success-only are mutually exclusive")
+	}
+
+	sendOptions, err := parseSendArgs(sendArgs)
+	var argErr *argumentError
+	if errors.As(err, &argErr) {
+		argErr.index = sendArgIndexes[argErr.index]
+	}
+	if err != nil {
+		return nil, err
+	}
+	options.send = sendOptions
+	return &options, nil
+}
+
+func runOutputLimits(options *sendOptions) stdinLimits {
+	limits := options.stdinLimits()
+	if limits.head == 0 && limits.tail == 0 {
+		limits.tail = defaultRunOutputTail
+	}
+	if limits.maxBytes == 0 {
+		limits.maxBytes = defaultRunOutputMaxBytes
+	}
+	return limits
+}
+
+// outputCapture keeps what will be sent of one output stream while the
+// command is running, redacting and limiting it line by line like stdin.
+type outputCapture struct {
+	writer  *io.PipeWriter
+	done    chan struct{}
+	content *stdinContent
+}
+
+func newOutputCapture(limits stdinLimits, filter *outputFilter) *outputCapture {
+	reader, writer := io.Pipe()
+	capture := &outputCapture{writer: writer, done: make(chan struct{})}
+	go func() {
+		capture.content, _ = readLimitedLines(reader, limits, filter.newLineRedactor().filterLine)
+		// Reading only fails if the pipe does, but never leave the command
+		// blocked on a write.
+		io.Copy(ioutil.Discard, reader)
+		close(capture.done)
+	}()
+	return capture
+}
+
+func (capture *outputCapture) Write(data []byte) (int, error) {
+	return capture.writer.Write(data)
+}
+
+// finish waits for the rest of the output to be read, once the command has
+// exited.
+func (capture *outputCapture) finish() *stdinContent {
+	capture.writer.Close()
+	<-capture.done
+	return capture.content
+}
+
+func executeCommand(command []string, tee bool, limits stdinLimits, filter *outputFilter) runResult {
+	result := runResult{
+		command: command,
+	}
+	result.hostname, _ = os.Hostname()
+
+	stdout := newOutputCapture(limits, filter)
+	stderr := newOutputCapture(limits, filter)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	if tee {
+		cmd.Stdout = io.MultiWriter(stdout, os.Stdout)
+		cmd.Stderr = io.MultiWriter(stderr, os.Stderr)
+	} else {
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
+
+	result.startedAt = time.Now()
+	err := cmd.Run()
+	result.duration = time.Since(result.startedAt)
+	result.stdout = stdout.finish()
+	result.stderr = stderr.finish()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.exitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result.signal = status.Signal()
+			result.exitCode = runSignaledExitCodeBase + int(result.signal)
+		}
+	} else if err != nil {
+		result.startErr = err
+		result.exitCode = runStartFailedExitCode
+	}
+	return result
+}
+
+func (result runResult) commandLine() string {
+	return strings.Join(result.command, " ")
+}
+
+func runResultSubject(result runResult) string {
+	if result.exitCode == 0 {
+		return "Command succeeded: " + result.commandLine()
+	}
+	if result.signal != 0 {
+		return "Command killed (" + signalName(result.signal) + "): " + result.commandLine()
+	}
+	return "Command failed (exit status " + strconv.Itoa(result.exitCode) + "): " + result.commandLine()
+}
+
+func runResultToBlocks(result runResult) []sendBlock {
+	blocks := make([]sendBlock, 0)
+
+	alert := sendBlock{
+		blockType: mendsail.BlockTypeAlert,
+		style:     "success",
+		text:      "Command exited with status 0",
+	}
+	if result.startErr != nil {
+		alert.style = "danger"
+		alert.text = "Command could not be started: " + result.startErr.Error()
+	} else if result.signal != 0 {
+		alert.style = "danger"
+		alert.text = "Command was killed by " + signalName(result.signal) + " (" + result.signal.String() + ")"
+	} else if result.exitCode != 0 {
+		alert.style = "danger"
+		alert.text = "Command exited with status " + strconv.Itoa(result.exitCode)
+	}
+	blocks = append(blocks, alert)
+
+	exitStatus := strconv.Itoa(result.exitCode)
+	if result.signal != 0 {
+		exitStatus += " (" + signalName(result.signal) + ")"
+	}
+	blocks = append(blocks, sendBlock{
+		blockType: mendsail.BlockTypeKeyValue,
+		pairs: []mendsail.Pair{
+			{Key: "Command", Value: result.commandLine()},
+			{Key: "Exit status", Value: exitStatus},
+			{Key: "Duration", Value: result.duration.Round(time.Millisecond).String()},
+			{Key: "Host", Value: result.hostname},
+			{Key: "Started at", Value: result.startedAt.Format(time.RFC3339)},
+		},
+	})
+
+	streams := []struct {
+		name    string
+		content *stdinContent
+	}{
+		{"stdout", result.stdout},
+		{"stderr", result.stderr},
+	}
+	for _, stream := range streams {
+		if stream.content == nil || len(stream.content.lines()) == 0 {
+			continue
+		}
+		blocks = append(blocks, sendBlock{
+			blockType: mendsail.BlockTypeParagraph,
+			text:      stream.name + ":",
+		})
+		blocks = append(blocks, sendBlock{
+			blockType: mendsail.BlockTypeCodeBlock,
+			text:      stream.content.text(),
+		})
+	}
+
+	return blocks
+}
+
+func runRun(args []string) error {
+	options, err1 := parseRunArgs(args)
+	if err1 != nil {
+		return usageError(err1)
+	}
+
+	if err := applyMessageFile(options.send); err != nil {
+		return err
+	}
+	if err := applySettings(options.send); err != nil {
+		return err
+	}
+	if err := applyTemplates(options.send); err != nil {
+		return err
+	}
+	if _, err := expandSourceBlocks(options.send, false, nil, os.Stderr); err != nil {
+		return err
+	}
+
+	// The default subject depends on the outcome, but everything else is
+	// validated up front so that a typo in --to doesn't surface only after a
+	// long-running job.
+	preflight := *options.send
+	if preflight.subject == "" {
+		preflight.subject = runResultSubject(runResult{command: options.command})
+	}
+	err2 := validateSendOptions(preflight)
+	if err2 != nil {
+		return validationError(err2)
+	}
+
+	filter, err3 := newOutputFilter(options.send.apiKey, options.send.redact)
+	if err3 != nil {
+		return err3
+	}
+
+	result := executeCommand(options.command, options.tee, runOutputLimits(options.send), filter)
+	exitErr := &exitCodeError{code: result.exitCode}
+	if result.startErr != nil {
+		exitErr.err = fmt.Errorf("could not start command: %s", result.startErr)
+	}
+
+	failed := result.exitCode != 0
+	if (options.onFailureOnly && !failed) || (options.onSuccessOnly && failed) {
+		if failed {
+			return exitErr
+		}
+		return nil
+	}
+
+	if options.send.subject == "" {
+		options.send.subject = runResultSubject(result)
+	}
+	options.send.blocks = append(options.send.blocks, runResultToBlocks(result)...)
+	// Attachments are read only now, as the command may have written them.
+	err4 := loadAttachments(options.send)
+	if err4 == nil {
+		err4 = deliverEmail(*options.send)
+	}
+	if err4 != nil {
+		err4 = options.send.reportError(err4)
+		if !failed {
+			return err4
+		}
+		// The command's exit status wins over a delivery error, so that a cron
+		// job or CI step fails the way the command did. The delivery error is
+		// still printed.
+		if err4.Error() != "" {
+			fmt.Println(err4)
+		}
+	}
+
+	if failed {
+		return exitErr
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_parseRunArgs_Basic(t *testing.T) {
+	args := []string{
+		"--to", "foobar@example.com",
+		"--on-failure-only",
+		"--heading", "Nightly backup",
+		"--", "backup.sh", "--full",
+	}
+	options, err := parseRunArgs(args)
+	expectNoError(t, err)
+	expectedCommand := []string{"backup.sh", "--full"}
+	if !reflect.DeepEqual(expectedCommand, options.command) {
+		t.Errorf("command: expected=%s actual=%s", expectedCommand, options.command)
+	}
+	if !options.onFailureOnly || options.onSuccessOnly || options.tee {
+		t.Errorf("flags: onFailureOnly=%t onSuccessOnly=%t tee=%t",
+			options.onFailureOnly, options.onSuccessOnly, options.tee)
+	}
+	expected := sendOptions{
+		to: []string{"foobar@example.com"},
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Nightly backup"},
+		},
+	}
+	exceptOptions(t, expected, options.send, err)
+}
+
+func Test_parseRunArgs_Separator(t *testing.T) {
+	args := []string{
+		"--paragraph=--verbose",
+		"--list", "a", "b",
+		"--", "git", "log", "--", "README.md",
+	}
+	options, err := parseRunArgs(args)
+	expectNoError(t, err)
+	expectedCommand := []string{"git", "log", "--", "README.md"}
+	if !reflect.DeepEqual(expectedCommand, options.command) {
+		t.Errorf("command: expected=%s actual=%s", expectedCommand, options.command)
+	}
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "--verbose"},
+			sendBlock{blockType: "List", items: []string{"a", "b"}},
+		},
+	}
+	exceptOptions(t, expected, options.send, err)
+}
+
+func Test_parseRunArgs_MissingCommand(t *testing.T) {
+	_, err := parseRunArgs([]string{"--to", "foobar@example.com"})
+	expectError(t, "missing command, usage: mendsail run <options> -- <command> [args...]", err)
+	_, err = parseRunArgs([]string{"--to", "foobar@example.com", "--"})
+	expectError(t, "missing command, usage: mendsail run <options> -- <command> [args...]", err)
+}
+
+func Test_parseRunArgs_ExclusiveFlags(t *testing.T) {
+	args := []string{"--on-failure-only", "--on-success-only", "--", "true"}
+	_, err := parseRunArgs(args)
+	expectError(t, "--on-failure-only and --on-success-only are mutually exclusive", err)
+}
+
+func testOutputFilter(t *testing.T) *outputFilter {
+	filter, err := newOutputFilter("api-key-123", nil)
+	expectNoError(t, err)
+	return filter
+}
+
+func Test_executeCommand_CapturesStreams(t *testing.T) {
+	result := executeCommand([]string{"sh", "-c", "echo out; echo err >&2; exit 3"}, false, stdinLimits{}, testOutputFilter(t))
+	if result.startErr != nil {
+		t.Fatalf("startErr: expected=nil actual=%s", result.startErr)
+	}
+	if result.exitCode != 3 {
+		t.Errorf("exitCode: expected=%d actual=%d", 3, result.exitCode)
+	}
+	exceptStringsEqual(t, "out\n", result.stdout.text())
+	exceptStringsEqual(t, "err\n", result.stderr.text())
+}
+
+func Test_executeCommand_LimitsAndRedactsOutput(t *testing.T) {
+	script := "for i in $(seq 1 1000); do echo line $i; done; echo key api-key-123; seq 1 100000 >&2"
+	result := executeCommand([]string{"sh", "-c", script}, false, stdinLimits{head: 1, tail: 2}, testOutputFilter(t))
+	exceptStringsEqual(t, "line 1\n… 998 lines truncated …\nline 1000\nkey [REDACTED]\n", result.stdout.text())
+
+	options := sendOptions{}
+	result = executeCommand([]string{"sh", "-c", script}, false, runOutputLimits(&options), testOutputFilter(t))
+	if len(result.stderr.tail) != defaultRunOutputTail {
+		t.Errorf("len(stderr.tail): expected=%d actual=%d", defaultRunOutputTail, len(result.stderr.tail))
+	}
+	exceptStringsEqual(t, "100000", result.stderr.tail[len(result.stderr.tail)-1])
+	exceptStringsEqual(t, "… 99800 lines truncated …", result.stderr.marker())
+}
+
+func Test_executeCommand_StartFailure(t *testing.T) {
+	result := executeCommand([]string{"mendsail-test-command-does-not-exist"}, false, stdinLimits{}, testOutputFilter(t))
+	if result.startErr == nil {
+		t.Errorf("startErr: expected error, actual=nil")
+	}
+	if result.exitCode != 127 {
+		t.Errorf("exitCode: expected=%d actual=%d", 127, result.exitCode)
+	}
+}
+
+func Test_executeCommand_Signaled(t *testing.T) {
+	result := executeCommand([]string{"sh", "-c", "kill -TERM $$"}, false, stdinLimits{}, testOutputFilter(t))
+	if result.exitCode != 143 {
+		t.Errorf("exitCode: expected=%d actual=%d", 143, result.exitCode)
+	}
+	exceptStringsEqual(t, "Command killed (SIGTERM): sh -c kill -TERM $$", runResultSubject(result))
+	blocks := runResultToBlocks(result)
+	exceptStringsEqual(t, "Command was killed by SIGTERM (terminated)", blocks[0].text)
+	exceptStringsEqual(t, "Exit status: 143 (SIGTERM)", blocks[1].pairs[1].Key+": "+blocks[1].pairs[1].Value)
+}
+
+func Test_runResultToBlocks_Failure(t *testing.T) {
+	result := runResult{
+		command:  []string{"backup.sh", "--full"},
+		stdout:   &stdinContent{head: []string{"out"}},
+		stderr:   &stdinContent{head: []string{"err"}},
+		exitCode: 2,
+		hostname: "host-1",
+	}
+	blocks := runResultToBlocks(result)
+	if len(blocks) != 6 {
+		t.Fatalf("len(blocks): expected=%d actual=%d", 6, len(blocks))
+	}
+	exceptStringsEqual(t, "Alert", blocks[0].blockType)
+	exceptStringsEqual(t, "danger", blocks[0].style)
+	exceptStringsEqual(t, "Command exited with status 2", blocks[0].text)
+	exceptStringsEqual(t, "KeyValue", blocks[1].blockType)
+	exceptStringsEqual(t, "Command: backup.sh --full", blocks[1].pairs[0].Key+": "+blocks[1].pairs[0].Value)
+	exceptStringsEqual(t, "Exit status: 2", blocks[1].pairs[1].Key+": "+blocks[1].pairs[1].Value)
+	exceptStringsEqual(t, "Host: host-1", blocks[1].pairs[3].Key+": "+blocks[1].pairs[3].Value)
+	exceptStringsEqual(t, "stdout:", blocks[2].text)
+	exceptStringsEqual(t, "out\n", blocks[3].text)
+	exceptStringsEqual(t, "stderr:", blocks[4].text)
+	exceptStringsEqual(t, "err\n", blocks[5].text)
+}
+
+func Test_runResultToBlocks_SuccessWithoutOutput(t *testing.T) {
+	result := runResult{
+		command: []string{"true"},
+	}
+	blocks := runResultToBlocks(result)
+	if len(blocks) != 2 {
+		t.Fatalf("len(blocks): expected=%d actual=%d", 2, len(blocks))
+	}
+	exceptStringsEqual(t, "success", blocks[0].style)
+}
+
+func Test_runResultSubject(t *testing.T) {
+	exceptStringsEqual(t, "Command succeeded: true",
+		runResultSubject(runResult{command: []string{"true"}}))
+	exceptStringsEqual(t, "Command failed (exit status 1): false",
+		runResultSubject(runResult{command: []string{"false"}, exitCode: 1}))
+}
+
+func Test_parseRunArgs_ArgumentIndex(t *testing.T) {
+	_, err := parseRunArgs([]string{"--tee", "--on-failure-only", "--foobar", "x", "--", "true"})
+	expectError(t, "Unrecognized option: --foobar (argument 3)", err)
+}
+
+func Test_runRun_DeliveryErrorKeepsExitStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+	}))
+	defer server.Close()
+	writeConfig(t, "")
+	t.Setenv("MENDSAIL_PROFILE", "")
+	t.Setenv("MENDSAIL_BASE_URL", server.URL)
+	args := []string{"--to", "foobar@example.com", "--api-key", "api-key-123", "--"}
+
+	err := runRun(append(args, "sh", "-c", "exit 3"))
+	expectExitCode(t, 3, err)
+	err = runRun(append(args, "true"))
+	expectExitCode(t, 3, err)
+}
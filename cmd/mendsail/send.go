@@ -0,0 +1,539 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+type sendBlock struct {
+	blockType string
+	text      string
+	items     []string
+	url       string
+	style     string
+	alt       string
+	width     int
+	ghost     bool
+	headers   []string
+	rows      [][]string
+	pairs     []mendsail.Pair
+	cite      string
+	size      string
+}
+
+type sendOptions struct {
+	profile     string
+	apiKey      string
+	apiKeyFile  string
+	apiKeyStdin bool
+	baseUrl     string
+	to          []string
+	cc          []string
+	bcc         []string
+	replyTo     string
+	subject     string
+	blocks      []sendBlock
+	file        string
+	// Settings from the --file message file, and the lines they're on.
+	fileValues  map[string][]string
+	fileLines   map[string]int
+	dump        bool
+	dryRun      bool
+	output      string
+	preview     string
+	previewFile string
+
+	subjectPrefix string
+
+	vars             map[string]string
+	varsFiles        []string
+	allowMissingVars bool
+	noTemplates      bool
+
+	stdinAs       string
+	stdinPosition string
+	stdinHead     int
+	stdinTail     int
+	stdinMaxBytes int
+
+	redact []string
+
+	attachments    []sendAttachment
+	attachCompress bool
+	attachMaxBytes int
+
+	timeout        time.Duration
+	retries        int
+	retryMaxWait   time.Duration
+	spoolOnFailure bool
+}
+
+func parseSendArgs(args []string) (*sendOptions, error) {
+	options := sendOptions{
+		retries: -1,
+	}
+	blocks := make([]sendBlock, 0)
+
+	scanner := newArgScanner(args)
+	for scanner.more() {
+		arg, err := scanner.option()
+		if err != nil {
+			return nil, err
+		}
+		if err := parseSendOption(scanner, arg, &options, &blocks); err != nil {
+			return nil, scanner.fail(err)
+		}
+	}
+
+	if options.previewFile != "" && options.preview == "" {
+		options.preview = PreviewHtml
+		if strings.HasSuffix(options.previewFile, ".txt") {
+			options.preview = PreviewText
+		}
+	}
+
+	if options.noTemplates && (len(options.vars) > 0 || len(options.varsFiles) > 0) {
+		return nil, errors.New("--no-templates can't be combined with --var or --vars-file")
+	}
+
+	options.blocks = blocks
+	return &options, nil
+}
+
+// sendFlags are the options that don't take a value.
+var sendFlags = valuelessOptions(sendOptionGroups)
+
+// parseSendOption reads the values of option arg, if any, into options and
+// blocks.
+func parseSendOption(scanner *argScanner, arg string, options *sendOptions, blocks *[]sendBlock) error {
+	if spec, ok := blockSpecs[arg]; ok {
+		block, err := parseBlock(scanner, arg, spec)
+		if err != nil {
+			return err
+		}
+		*blocks = append(*blocks, block)
+		return nil
+	}
+
+	if sendFlags[arg] {
+		if err := scanner.flag(arg); err != nil {
+			return err
+		}
+		switch arg {
+		case "--dump", "--dry-run":
+			// --dump is the deprecated name of --dry-run.
+			options.dump = arg == "--dump"
+			options.dryRun = true
+		case "--api-key-stdin":
+			options.apiKeyStdin = true
+		case "--spool-on-failure":
+			options.spoolOnFailure = true
+		case "--allow-missing-vars":
+			options.allowMissingVars = true
+		case "--no-templates":
+			options.noTemplates = true
+		case "--attach-compress":
+			options.attachCompress = true
+		}
+		return nil
+	}
+
+	if arg == "--attach" {
+		attachment, err := parseAttach(scanner, arg)
+		if err != nil {
+			return err
+		}
+		options.attachments = append(options.attachments, attachment)
+		return nil
+	}
+
+	if arg == endOption {
+		return errors.New(endOption + " doesn't follow a list")
+	}
+
+	value, err := scanner.value(arg)
+	if err != nil {
+		return err
+	}
+
+	switch arg {
+	case "--profile":
+		options.profile = value
+	case "--api-key":
+		options.apiKey = value
+	case "--api-key-file":
+		options.apiKeyFile = value
+	case "--to":
+		options.to = append(options.to, value)
+	case "--cc":
+		options.cc = append(options.cc, value)
+	case "--bcc":
+		options.bcc = append(options.bcc, value)
+	case "--reply-to":
+		options.replyTo = value
+	case "--subject":
+		options.subject = value
+	case "--file":
+		options.file = value
+	case "--preview":
+		if err := validatePreview(value); err != nil {
+			return err
+		}
+		options.preview = value
+	case "--preview-file":
+		options.previewFile = value
+	case "--output":
+		if err := validateOutput(value); err != nil {
+			return err
+		}
+		options.output = value
+	case "--var":
+		key, varValue, err := parseVar(value)
+		if err != nil {
+			return err
+		}
+		if options.vars == nil {
+			options.vars = make(map[string]string)
+		}
+		options.vars[key] = varValue
+	case "--vars-file":
+		options.varsFiles = append(options.varsFiles, value)
+	case "--timeout", "--retry-max-wait":
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return errors.New("invalid value for " + arg + ": '" + value + "' (should be a duration such as 30s or 1m)")
+		}
+		if arg == "--timeout" {
+			options.timeout = duration
+		} else {
+			options.retryMaxWait = duration
+		}
+	case "--retries":
+		retries, err := parseNonNegativeInt(arg, value)
+		if err != nil {
+			return err
+		}
+		options.retries = retries
+	case "--redact":
+		if err := validateRedactPattern(value); err != nil {
+			return err
+		}
+		options.redact = append(options.redact, value)
+	case "--stdin-as":
+		if err := validateStdinAs(value); err != nil {
+			return err
+		}
+		options.stdinAs = value
+	case "--stdin-position":
+		if err := validateStdinPosition(value); err != nil {
+			return err
+		}
+		options.stdinPosition = value
+	case "--attach-max-bytes":
+		number, err := parseAttachMaxBytes(arg, value)
+		if err != nil {
+			return err
+		}
+		options.attachMaxBytes = number
+	case "--stdin-head", "--stdin-tail", "--stdin-max-bytes":
+		number, err := parseNonNegativeInt(arg, value)
+		if err != nil {
+			return err
+		}
+		switch arg {
+		case "--stdin-head":
+			options.stdinHead = number
+		case "--stdin-tail":
+			options.stdinTail = number
+		default:
+			options.stdinMaxBytes = number
+		}
+	case "--kv":
+		pair, err := parseKv(value)
+		if err != nil {
+			return err
+		}
+		return addKv(blocks, pair)
+	default:
+		return errors.New("Unrecognized option: " + arg)
+	}
+	return nil
+}
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+func validateOutput(value string) error {
+	if value == OutputText || value == OutputJson {
+		return nil
+	}
+	return errors.New("invalid value for --output: '" + value + "' (should be text or json)")
+}
+
+func validateStyle(style string) error {
+	if style != "success" && style != "warning" && style != "danger" && style != "info" {
+		return errors.New("invalid style: '" + style + "' (should be one of: success, warning, danger, info)")
+	}
+	return nil
+}
+
+func validateSendOptions(options sendOptions) error {
+	// A preview or a dry run never reaches the API, so it doesn't need a key.
+	if options.apiKey == "" && options.preview == "" && !options.dryRun {
+		return errors.New("missing option: --api-key")
+	}
+	if len(options.to) == 0 {
+		return errors.New("missing option: --to")
+	}
+	if options.subject == "" {
+		return errors.New("missing option: --subject")
+	}
+	if err := validateAddresses("--to", options.to); err != nil {
+		return err
+	}
+	if err := validateAddresses("--cc", options.cc); err != nil {
+		return err
+	}
+	if err := validateAddresses("--bcc", options.bcc); err != nil {
+		return err
+	}
+	if options.replyTo != "" {
+		if err := validateAddresses("--reply-to", []string{options.replyTo}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAddresses(option string, addresses []string) error {
+	for _, address := range addresses {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return errors.New("invalid email address for " + option + ": '" + address + "'")
+		}
+	}
+	return nil
+}
+
+// Every block type must have an entry here, otherwise sendOptionsToJsonPayload
+// refuses to build the payload instead of sending an empty block.
+var blockSerializers = map[string]func(block sendBlock) mendsail.Block{
+	mendsail.BlockTypeHeading: func(block sendBlock) mendsail.Block {
+		return mendsail.Heading(block.text)
+	},
+	mendsail.BlockTypeParagraph: func(block sendBlock) mendsail.Block {
+		return mendsail.Paragraph(block.text)
+	},
+	mendsail.BlockTypeCodeBlock: func(block sendBlock) mendsail.Block {
+		return mendsail.CodeBlock(block.text)
+	},
+	mendsail.BlockTypeList: func(block sendBlock) mendsail.Block {
+		return mendsail.List(block.items...)
+	},
+	mendsail.BlockTypeImage: func(block sendBlock) mendsail.Block {
+		return mendsail.Image(block.url, block.alt, block.width)
+	},
+	mendsail.BlockTypeAlert: func(block sendBlock) mendsail.Block {
+		return mendsail.Alert(block.text, block.style)
+	},
+	mendsail.BlockTypeLink: func(block sendBlock) mendsail.Block {
+		return mendsail.Link(block.url, block.text)
+	},
+	mendsail.BlockTypeButton: func(block sendBlock) mendsail.Block {
+		return mendsail.Button(block.url, block.text, block.style, block.ghost)
+	},
+	mendsail.BlockTypeTable: func(block sendBlock) mendsail.Block {
+		return mendsail.Table(block.headers, block.rows...)
+	},
+	mendsail.BlockTypeKeyValue: func(block sendBlock) mendsail.Block {
+		return mendsail.KeyValue(block.pairs...)
+	},
+	mendsail.BlockTypeDivider: func(block sendBlock) mendsail.Block {
+		return mendsail.Divider()
+	},
+	mendsail.BlockTypeQuote: func(block sendBlock) mendsail.Block {
+		return mendsail.Quote(block.text, block.cite)
+	},
+	mendsail.BlockTypeSpacer: func(block sendBlock) mendsail.Block {
+		return mendsail.Spacer(block.size)
+	},
+}
+
+func sendBlockToPayload(block sendBlock) (mendsail.Block, error) {
+	serialize, ok := blockSerializers[block.blockType]
+	if !ok {
+		return mendsail.Block{}, errors.New("unsupported block type: '" + block.blockType + "'")
+	}
+	return serialize(block), nil
+}
+
+func sendOptionsToPayload(options sendOptions) (*mendsail.Message, error) {
+	blocks := []mendsail.Block{}
+	for _, block := range options.blocks {
+		blockPayload, err := sendBlockToPayload(block)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, blockPayload)
+	}
+	return &mendsail.Message{
+		To:          options.to,
+		Cc:          options.cc,
+		Bcc:         options.bcc,
+		ReplyTo:     options.replyTo,
+		Subject:     options.subjectPrefix + options.subject,
+		Blocks:      blocks,
+		Attachments: sendAttachmentsToPayload(options.attachments),
+	}, nil
+}
+
+func sendOptionsToJsonPayload(options sendOptions) ([]byte, error) {
+	payload, err := sendOptionsToPayload(options)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(payload)
+}
+
+// retryPolicy fills in defaults for whatever wasn't given on the command line.
+func (options *sendOptions) retryPolicy() mendsail.RetryPolicy {
+	policy := mendsail.DefaultRetryPolicy
+	if options.retries >= 0 {
+		policy.Retries = options.retries
+	}
+	if options.retryMaxWait != 0 {
+		policy.MaxWait = options.retryMaxWait
+	}
+	return policy
+}
+
+func runSend(args []string) error {
+	options, err := parseSendArgs(args)
+	if err != nil {
+		return usageError(err)
+	}
+	return options.reportError(sendEmail(options))
+}
+
+func sendEmail(options *sendOptions) error {
+	if err := applyMessageFile(options); err != nil {
+		return err
+	}
+	if err := applySettings(options); err != nil {
+		return err
+	}
+
+	if err := applyTemplates(options); err != nil {
+		return err
+	}
+
+	filter, err2 := newOutputFilter(options.apiKey, options.redact)
+	if err2 != nil {
+		return err2
+	}
+
+	didReadStdin := false
+	stdinContent := &stdinContent{}
+	if options.wantsStdin() {
+		var stdinError error
+		didReadStdin, stdinContent, stdinError = readStdin(options.stdinLimits(), filter)
+		if stdinError != nil {
+			return stdinError
+		}
+	}
+
+	usedStdin, err3 := expandSourceBlocks(options, didReadStdin, []byte(stdinContent.text()), os.Stderr)
+	if err3 != nil {
+		return err3
+	}
+
+	err4 := validateSendOptions(*options)
+	if err4 != nil {
+		return validationError(err4)
+	}
+	if err := loadAttachments(options); err != nil {
+		return err
+	}
+
+	if didReadStdin && !usedStdin && len(stdinContent.lines()) > 0 {
+		err5 := insertStdinBlocks(options, stdinToBlocks(options.stdinAs, stdinContent, os.Stderr))
+		if err5 != nil {
+			return err5
+		}
+	}
+
+	return deliverEmail(*options)
+}
+
+func deliverEmail(options sendOptions) error {
+	if options.preview != "" {
+		payload, err := sendOptionsToPayload(options)
+		if err != nil {
+			return err
+		}
+		return writePreview(payload, options.preview, options.previewFile)
+	}
+
+	message, err1 := sendOptionsToPayload(options)
+	if err1 != nil {
+		return err1
+	}
+
+	client := newApiClient(options.baseUrl, options.apiKey, options.timeout, options.retryPolicy())
+	idempotencyKey := mendsail.NewIdempotencyKey()
+
+	if options.dryRun {
+		if options.dump {
+			fmt.Fprintln(os.Stderr, "warning: --dump is deprecated, use --dry-run instead")
+		}
+		if options.output == OutputJson {
+			return writeJson(os.Stdout, message)
+		}
+		req, err := client.NewRequest(context.Background(), message, idempotencyKey)
+		if err != nil {
+			return err
+		}
+		if err := writeDryRun(os.Stdout, req); err != nil {
+			return err
+		}
+		writeTableFallbacks(os.Stdout, message)
+		return nil
+	}
+
+	response, err2 := client.Send(context.Background(), message, mendsail.WithIdempotencyKey(idempotencyKey))
+	// Only queue emails that may go through later. An invalid email or a
+	// revoked key would fail on every flush.
+	if err2 != nil && options.spoolOnFailure && isTemporary(err2) {
+		path, spoolErr := spoolEmail(client.BaseUrl()+"/emails", options.profile, idempotencyKey, message)
+		if spoolErr != nil {
+			return errors.New(err2.Error() + "\nCould not queue the email either: " + spoolErr.Error())
+		}
+		fmt.Fprintln(os.Stderr, err2)
+		if options.output == OutputJson {
+			result := sendResult{Result: *response, QueuedAs: path}
+			result.Status = SendStatusQueued
+			return writeJson(os.Stdout, result)
+		}
+		fmt.Println("Email could not be sent and was queued as " + path + ", use \"mendsail queue flush\" to retry.")
+		return nil
+	}
+	if err2 != nil {
+		return err2
+	}
+
+	if options.output == OutputJson {
+		return writeJson(os.Stdout, sendResult{Result: *response})
+	}
+	fmt.Println("Email sent successfully.")
+
+	return nil
+}
@@ -0,0 +1,818 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func exceptOptions(t *testing.T, expected sendOptions, actual *sendOptions, err error) {
+	if err != nil {
+		t.Errorf("err: expected=nil actual=%s", err)
+		t.FailNow()
+	}
+	if !reflect.DeepEqual(actual.to, expected.to) {
+		t.Errorf("sendOptions.to: expected=%s actual=%s", expected.to, actual.to)
+	}
+	if !reflect.DeepEqual(actual.cc, expected.cc) {
+		t.Errorf("sendOptions.cc: expected=%s actual=%s", expected.cc, actual.cc)
+	}
+	if !reflect.DeepEqual(actual.bcc, expected.bcc) {
+		t.Errorf("sendOptions.bcc: expected=%s actual=%s", expected.bcc, actual.bcc)
+	}
+	if actual.replyTo != expected.replyTo {
+		t.Errorf("sendOptions.replyTo: expected=%s actual=%s", expected.replyTo, actual.replyTo)
+	}
+	if actual.subject != expected.subject {
+		t.Errorf("sendOptions.subject: expected=%s actual=%s", expected.subject, actual.subject)
+	}
+	if len(actual.blocks) != len(expected.blocks) {
+		t.Errorf("len(actual.blocks): expected=%d actual=%d", len(expected.blocks), len(actual.blocks))
+		t.FailNow()
+	}
+	for i, actualBlock := range actual.blocks {
+		expectedBlock := expected.blocks[i]
+		if actualBlock.blockType != expectedBlock.blockType {
+			t.Errorf("sendOptions.blocks[%d].blockType: expected=%s actual=%s",
+				i, actualBlock.blockType, expectedBlock.blockType)
+		}
+		if actualBlock.text != expectedBlock.text {
+			t.Errorf("sendOptions.blocks[%d].text: expected=%s actual=%s",
+				i, expectedBlock.text, actualBlock.text)
+		}
+		if actualBlock.url != expectedBlock.url {
+			t.Errorf("sendOptions.blocks[%d].url: expected=%s actual=%s",
+				i, expectedBlock.url, actualBlock.url)
+		}
+		if !reflect.DeepEqual(actualBlock.items, expectedBlock.items) {
+			t.Errorf("sendOptions.blocks[%d].items: expected=%s actual=%s",
+				i, expectedBlock.items, actualBlock.items)
+		}
+		if actualBlock.style != expectedBlock.style {
+			t.Errorf("sendOptions.blocks[%d].style: expected=%s actual=%s",
+				i, expectedBlock.style, actualBlock.style)
+		}
+		if actualBlock.alt != expectedBlock.alt {
+			t.Errorf("sendOptions.blocks[%d].alt: expected=%s actual=%s",
+				i, expectedBlock.alt, actualBlock.alt)
+		}
+		if actualBlock.width != expectedBlock.width {
+			t.Errorf("sendOptions.blocks[%d].width: expected=%d actual=%d",
+				i, expectedBlock.width, actualBlock.width)
+		}
+		if actualBlock.ghost != expectedBlock.ghost {
+			t.Errorf("sendOptions.blocks[%d].ghost: expected=%t actual=%t",
+				i, expectedBlock.ghost, actualBlock.ghost)
+		}
+		if !reflect.DeepEqual(actualBlock.headers, expectedBlock.headers) {
+			t.Errorf("sendOptions.blocks[%d].headers: expected=%s actual=%s",
+				i, expectedBlock.headers, actualBlock.headers)
+		}
+		if !reflect.DeepEqual(actualBlock.rows, expectedBlock.rows) {
+			t.Errorf("sendOptions.blocks[%d].rows: expected=%s actual=%s",
+				i, expectedBlock.rows, actualBlock.rows)
+		}
+		if actualBlock.cite != expectedBlock.cite {
+			t.Errorf("sendOptions.blocks[%d].cite: expected=%s actual=%s",
+				i, expectedBlock.cite, actualBlock.cite)
+		}
+		if actualBlock.size != expectedBlock.size {
+			t.Errorf("sendOptions.blocks[%d].size: expected=%s actual=%s",
+				i, expectedBlock.size, actualBlock.size)
+		}
+		if !reflect.DeepEqual(actualBlock.pairs, expectedBlock.pairs) {
+			t.Errorf("sendOptions.blocks[%d].pairs: expected=%v actual=%v",
+				i, expectedBlock.pairs, actualBlock.pairs)
+		}
+	}
+}
+
+func Test_parseSendArgs_Empty(t *testing.T) {
+	var args []string
+	expected := sendOptions{
+		apiKey:  "",
+		subject: "",
+		blocks:  []sendBlock{},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_Basic(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks:  []sendBlock{},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_Recipients(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foo@example.com",
+		"--to", "bar@example.com",
+		"--cc", "manager@example.com",
+		"--bcc", "archive@example.com",
+		"--bcc", "audit@example.com",
+		"--reply-to", "tickets@example.com",
+		"--subject", "example 123",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foo@example.com", "bar@example.com"},
+		cc:      []string{"manager@example.com"},
+		bcc:     []string{"archive@example.com", "audit@example.com"},
+		replyTo: "tickets@example.com",
+		subject: "example 123",
+		blocks:  []sendBlock{},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_File(t *testing.T) {
+	args := []string{
+		"--file", "message.yaml",
+		"--paragraph", "paragraph 1",
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "message.yaml", actual.file)
+	if len(actual.blocks) != 1 {
+		t.Errorf("len(actual.blocks): expected=%d actual=%d", 1, len(actual.blocks))
+	}
+}
+
+func Test_parseSendArgs_UnknownOption(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--foobar", "foobar@example.com",
+		"--subject", "example 123",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "Unrecognized option: --foobar (argument 3)", err)
+}
+
+func Test_parseSendArgs_MissingValue(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "missing value for --subject (argument 5)", err)
+}
+
+func Test_parseSendArgs_BlockTypes(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--heading", "Data processing failed",
+		"--paragraph", "Log output:",
+		"--list", "List item 1",
+		"--image", "https://example.com/image.png",
+		"--code-block", "code 1",
+		"--alert", "alert 1", "style:info",
+		"--link", "https://example.com",
+		"--button", "https://example.com", "Button text",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Data processing failed"},
+			sendBlock{blockType: "Paragraph", text: "Log output:"},
+			sendBlock{blockType: "List", items: []string{"List item 1"}},
+			sendBlock{blockType: "Image", url: "https://example.com/image.png"},
+			sendBlock{blockType: "CodeBlock", text: "code 1"},
+			sendBlock{blockType: "Alert", text: "alert 1", style: "info"},
+			sendBlock{blockType: "Link", url: "https://example.com", text: "https://example.com"},
+			sendBlock{blockType: "Button", url: "https://example.com", text: "Button text"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_BlockOrder(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--heading", "heading 1",
+		"--paragraph", "paragraph 1",
+		"--paragraph", "paragraph 2",
+		"--code-block", "code block 1",
+		"--code-block", "code block 2",
+		"--heading", "heading 2",
+		"--paragraph", "paragraph 3",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "heading 1"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 1"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 2"},
+			sendBlock{blockType: "CodeBlock", text: "code block 1"},
+			sendBlock{blockType: "CodeBlock", text: "code block 2"},
+			sendBlock{blockType: "Heading", text: "heading 2"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 3"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_Dump(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--heading", "Data processing failed",
+		"--dump",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		dump:    true,
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "Data processing failed"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_ListMultipleItems(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--list", "List item 1", "List item 2", "List item 3",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "List", items: []string{"List item 1", "List item 2", "List item 3"}},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_ImageOptions(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--image", "https://example.com/image.png", "alt:Alt text", "width:123",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Image", url: "https://example.com/image.png", alt: "Alt text", width: 123},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_UnknownImageWidthType(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--image", "https://example.com/image.png", "width:asd",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "could not parse width as an integer (argument 9)", err)
+}
+
+func Test_parseSendArgs_UnknownImageOption(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--image", "https://example.com/image.png", "foobar:Alt text",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "unknown option: 'foobar:Alt text' (argument 9)", err)
+}
+
+func Test_parseSendArgs_AlertOptions(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--alert", "lorem ipsum", "style:danger",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Alert", text: "lorem ipsum", style: "danger"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_UnknownAlertOption(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--alert", "lorem ipsum", "foobar:danger",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "unknown option: 'foobar:danger' (argument 9)", err)
+}
+
+func Test_parseSendArgs_InvalidAlertStyle(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--alert", "lorem ipsum", "style:foobar",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "invalid style: 'foobar' (should be one of: success, warning, danger, info) (argument 9)", err)
+}
+
+func Test_parseSendArgs_LinkText(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--link", "https://example.com", "text foobar",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Link", url: "https://example.com", text: "text foobar"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_ButtonOptions(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--button", "https://example.com", "lorem ipsum", "style:danger", "ghost:true",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Button", url: "https://example.com", text: "lorem ipsum", style: "danger", ghost: true},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_UnknownMissingText(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--button", "https://example.com",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "missing button text (argument 7)", err)
+}
+
+func Test_parseSendArgs_UnknownButtonOption(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--button", "https://example.com", "lorem ipsum", "foobar:danger",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "unknown option: 'foobar:danger' (argument 10)", err)
+}
+
+func Test_parseSendArgs_InvalidButtonStyle(t *testing.T) {
+	args := []string{
+		"--api-key", "foobar-123",
+		"--to", "foobar@example.com",
+		"--subject", "example 123",
+		"--button", "https://example.com", "lorem ipsum", "style:foobar",
+	}
+	_, err := parseSendArgs(args)
+	expectError(t, "invalid style: 'foobar' (should be one of: success, warning, danger, info) (argument 10)", err)
+}
+
+func Test_parseSendArgs_InlineValues(t *testing.T) {
+	args := []string{
+		"--to=foobar@example.com",
+		"--subject=a=b",
+		"--image=https://example.com/image.png", "alt:Alt text",
+		"--paragraph=",
+	}
+	expected := sendOptions{
+		to:      []string{"foobar@example.com"},
+		subject: "a=b",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Image", url: "https://example.com/image.png", alt: "Alt text"},
+			sendBlock{blockType: "Paragraph", text: ""},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_ShortAliases(t *testing.T) {
+	args := []string{
+		"-k", "foobar-123",
+		"-t", "foo@example.com",
+		"-t", "bar@example.com",
+		"-s", "example 123",
+	}
+	expected := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foo@example.com", "bar@example.com"},
+		subject: "example 123",
+		blocks:  []sendBlock{},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_Terminator(t *testing.T) {
+	actual, err := parseSendArgs([]string{"--heading", "-", "--paragraph", "--", "--help"})
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "-"},
+			sendBlock{blockType: "Paragraph", text: "--help"},
+		},
+	}
+	exceptOptions(t, expected, actual, err)
+
+	actual, err = parseSendArgs([]string{"--list", "-5", "--", "-a", "--b", "--end"})
+	expected = sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "List", items: []string{"-5", "-a", "--b", "--end"}},
+		},
+	}
+	exceptOptions(t, expected, actual, err)
+
+	_, err = parseSendArgs([]string{"--paragraph", "--", "--help", "--to"})
+	expectError(t, "unexpected argument: '--to' (argument 4)", err)
+}
+
+func Test_parseSendArgs_ListEnd(t *testing.T) {
+	args := []string{
+		"--list", "item 1", "item 2", "-t", "foo@example.com",
+		"--list", "item 3", "--end",
+		"--alert", "lorem ipsum", "style:danger", "--end",
+		"--heading", "heading 1",
+	}
+	expected := sendOptions{
+		to: []string{"foo@example.com"},
+		blocks: []sendBlock{
+			sendBlock{blockType: "List", items: []string{"item 1", "item 2"}},
+			sendBlock{blockType: "List", items: []string{"item 3"}},
+			sendBlock{blockType: "Alert", text: "lorem ipsum", style: "danger"},
+			sendBlock{blockType: "Heading", text: "heading 1"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+
+	_, err = parseSendArgs([]string{"--list", "item 1", "--end", "item 2"})
+	expectError(t, "unexpected argument: 'item 2' (argument 4)", err)
+	_, err = parseSendArgs([]string{"--heading", "heading 1", "--end"})
+	expectError(t, "--end doesn't follow a list (argument 3)", err)
+}
+
+func Test_parseSendArgs_ArgumentErrors(t *testing.T) {
+	_, err := parseSendArgs([]string{"--subject", "--to", "foo@example.com"})
+	expectError(t, "missing value for --subject (argument 1)", err)
+	_, err = parseSendArgs([]string{"--to", "foo@example.com", "bar@example.com"})
+	expectError(t, "unexpected argument: 'bar@example.com' (argument 3)", err)
+	_, err = parseSendArgs([]string{"--to", "foo@example.com", "--dry-run=yes"})
+	expectError(t, "--dry-run doesn't take a value (argument 3)", err)
+	_, err = parseSendArgs([]string{"-x", "foo"})
+	expectError(t, "Unrecognized option: -x (argument 1)", err)
+	_, err = parseSendArgs([]string{"--button", "https://example.com", "--heading", "foo"})
+	expectError(t, "missing button text (argument 1)", err)
+}
+
+func Test_validateSendOptions_Valid(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectNoError(t, err)
+}
+
+func Test_validateSendOptions_ApiKey(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "missing option: --api-key", err)
+	options.dryRun = true
+	expectNoError(t, validateSendOptions(options))
+}
+
+func Test_validateSendOptions_To(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "missing option: --to", err)
+}
+
+func Test_validateSendOptions_Subject(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "missing option: --subject", err)
+}
+
+func Test_validateSendOptions_DisplayName(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"Foo Bar <foobar@example.com>"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectNoError(t, err)
+}
+
+func Test_validateSendOptions_InvalidTo(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com", "foobar"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "invalid email address for --to: 'foobar'", err)
+}
+
+func Test_validateSendOptions_InvalidCc(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		cc:      []string{"foo bar@example.com"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "invalid email address for --cc: 'foo bar@example.com'", err)
+}
+
+func Test_validateSendOptions_InvalidBcc(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		bcc:     []string{"@example.com"},
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "invalid email address for --bcc: '@example.com'", err)
+}
+
+func Test_validateSendOptions_InvalidReplyTo(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		replyTo: "tickets",
+		subject: "example 123",
+	}
+	err := validateSendOptions(options)
+	expectError(t, "invalid email address for --reply-to: 'tickets'", err)
+}
+
+func Test_sendOptionsToJsonPayload_Recipients(t *testing.T) {
+	options := sendOptions{
+		to:      []string{"foo@example.com", "bar@example.com"},
+		cc:      []string{"manager@example.com"},
+		bcc:     []string{"archive@example.com"},
+		replyTo: "tickets@example.com",
+		subject: "example 123",
+	}
+	expected := "{" +
+		"\"to\":[\"foo@example.com\",\"bar@example.com\"]," +
+		"\"cc\":[\"manager@example.com\"]," +
+		"\"bcc\":[\"archive@example.com\"]," +
+		"\"replyTo\":\"tickets@example.com\"," +
+		"\"subject\":\"example 123\"," +
+		"\"blocks\":[]" +
+		"}"
+	actual, err := sendOptionsToJsonPayload(options)
+	expectNoError(t, err)
+	exceptStringsEqual(t, expected, string(actual))
+}
+
+// The API takes "to" as an array, also for a single recipient.
+func Test_sendOptionsToJsonPayload_SingleRecipient(t *testing.T) {
+	options, err := parseSendArgs([]string{"--to", "a@example.com", "--subject", "example 123"})
+	expectNoError(t, err)
+	actual, err := sendOptionsToJsonPayload(*options)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "{\"to\":[\"a@example.com\"],\"subject\":\"example 123\",\"blocks\":[]}", string(actual))
+}
+
+func Test_sendOptionsToJsonPayload_works(t *testing.T) {
+	options := sendOptions{
+		apiKey:  "foobar-123",
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Heading", text: "heading 1"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 1"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 2"},
+			sendBlock{blockType: "CodeBlock", text: "code block 1"},
+			sendBlock{blockType: "CodeBlock", text: "code block 2"},
+			sendBlock{blockType: "Heading", text: "heading 2"},
+			sendBlock{blockType: "Paragraph", text: "paragraph 3"},
+			sendBlock{blockType: "List", items: []string{"item 1", "item 2"}},
+			sendBlock{blockType: "Image", url: "image.png"},
+			sendBlock{blockType: "Image", url: "image.png", alt: "alt text", width: 123},
+			sendBlock{blockType: "Alert", text: "alert 1", style: "info"},
+			sendBlock{blockType: "Link", url: "https://example.com", text: "lorem ipsum"},
+		},
+	}
+	expected := "{" +
+		"\"to\":[\"foobar@example.com\"]," +
+		"\"subject\":\"example 123\"," +
+		"\"blocks\":[" +
+		"{\"type\":\"Heading\",\"text\":\"heading 1\"}," +
+		"{\"type\":\"Paragraph\",\"text\":\"paragraph 1\"}," +
+		"{\"type\":\"Paragraph\",\"text\":\"paragraph 2\"}," +
+		"{\"type\":\"CodeBlock\",\"text\":\"code block 1\"}," +
+		"{\"type\":\"CodeBlock\",\"text\":\"code block 2\"}," +
+		"{\"type\":\"Heading\",\"text\":\"heading 2\"}," +
+		"{\"type\":\"Paragraph\",\"text\":\"paragraph 3\"}," +
+		"{\"type\":\"List\",\"items\":[\"item 1\",\"item 2\"]}," +
+		"{\"type\":\"Image\",\"url\":\"image.png\"}," +
+		"{\"type\":\"Image\",\"url\":\"image.png\",\"alt\":\"alt text\",\"width\":123}," +
+		"{\"type\":\"Alert\",\"text\":\"alert 1\",\"style\":\"info\"}," +
+		"{\"type\":\"Link\",\"text\":\"lorem ipsum\",\"url\":\"https://example.com\"}" +
+		"]" +
+		"}"
+	actual, err := sendOptionsToJsonPayload(options)
+	expectNoError(t, err)
+	exceptStringsEqual(t, expected, string(actual))
+}
+
+func Test_sendOptionsToJsonPayload_BlockTypes(t *testing.T) {
+	tests := []struct {
+		block    sendBlock
+		expected string
+	}{
+		{
+			sendBlock{blockType: "Heading", text: "heading 1"},
+			"{\"type\":\"Heading\",\"text\":\"heading 1\"}",
+		},
+		{
+			sendBlock{blockType: "Paragraph", text: "paragraph 1"},
+			"{\"type\":\"Paragraph\",\"text\":\"paragraph 1\"}",
+		},
+		{
+			sendBlock{blockType: "CodeBlock", text: "code block 1"},
+			"{\"type\":\"CodeBlock\",\"text\":\"code block 1\"}",
+		},
+		{
+			sendBlock{blockType: "List", items: []string{"item 1", "item 2"}},
+			"{\"type\":\"List\",\"items\":[\"item 1\",\"item 2\"]}",
+		},
+		{
+			sendBlock{blockType: "Image", url: "image.png", alt: "alt text", width: 123},
+			"{\"type\":\"Image\",\"url\":\"image.png\",\"alt\":\"alt text\",\"width\":123}",
+		},
+		{
+			sendBlock{blockType: "Alert", text: "alert 1", style: "danger"},
+			"{\"type\":\"Alert\",\"text\":\"alert 1\",\"style\":\"danger\"}",
+		},
+		{
+			sendBlock{blockType: "Link", url: "https://example.com", text: "lorem ipsum"},
+			"{\"type\":\"Link\",\"text\":\"lorem ipsum\",\"url\":\"https://example.com\"}",
+		},
+		{
+			sendBlock{blockType: "Button", url: "https://example.com", text: "lorem ipsum"},
+			"{\"type\":\"Button\",\"text\":\"lorem ipsum\",\"url\":\"https://example.com\"}",
+		},
+		{
+			sendBlock{blockType: "Button", url: "https://example.com", text: "lorem ipsum", style: "warning", ghost: true},
+			"{\"type\":\"Button\",\"text\":\"lorem ipsum\",\"url\":\"https://example.com\",\"style\":\"warning\",\"ghost\":true}",
+		},
+		{
+			sendBlock{blockType: "Table", headers: []string{"Host", "Disk"}, rows: [][]string{{"web-1", "91%"}}},
+			"{\"type\":\"Table\",\"headers\":[\"Host\",\"Disk\"],\"rows\":[[\"web-1\",\"91%\"]]}",
+		},
+		{
+			sendBlock{blockType: "KeyValue", pairs: []mendsail.Pair{{Key: "Host", Value: "web-1"}}},
+			"{\"type\":\"KeyValue\",\"pairs\":[{\"key\":\"Host\",\"value\":\"web-1\"}]}",
+		},
+		{
+			sendBlock{blockType: "Divider"},
+			"{\"type\":\"Divider\"}",
+		},
+		{
+			sendBlock{blockType: "Quote", text: "Ship it", cite: "Release notes"},
+			"{\"type\":\"Quote\",\"text\":\"Ship it\",\"cite\":\"Release notes\"}",
+		},
+		{
+			sendBlock{blockType: "Spacer", size: "large"},
+			"{\"type\":\"Spacer\",\"size\":\"large\"}",
+		},
+	}
+	for _, test := range tests {
+		options := sendOptions{
+			to:      []string{"foobar@example.com"},
+			subject: "example 123",
+			blocks:  []sendBlock{test.block},
+		}
+		expected := "{" +
+			"\"to\":[\"foobar@example.com\"]," +
+			"\"subject\":\"example 123\"," +
+			"\"blocks\":[" + test.expected + "]" +
+			"}"
+		actual, err := sendOptionsToJsonPayload(options)
+		expectNoError(t, err)
+		exceptStringsEqual(t, expected, string(actual))
+	}
+}
+
+func Test_sendOptionsToJsonPayload_EveryBlockTypeHasSerializer(t *testing.T) {
+	for _, blockType := range mendsail.BlockTypes {
+		if _, ok := blockSerializers[blockType]; !ok {
+			t.Errorf("blockSerializers: missing serializer for %s", blockType)
+		}
+	}
+}
+
+func Test_sendOptionsToJsonPayload_UnknownBlockType(t *testing.T) {
+	options := sendOptions{
+		to:      []string{"foobar@example.com"},
+		subject: "example 123",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Foobar", text: "foobar"},
+		},
+	}
+	_, err := sendOptionsToJsonPayload(options)
+	expectError(t, "unsupported block type: 'Foobar'", err)
+}
+
+func Test_parseSendArgs_DryRun(t *testing.T) {
+	options, err := parseSendArgs([]string{"--dry-run", "--output", "json"})
+	expectNoError(t, err)
+	if !options.dryRun || options.dump {
+		t.Errorf("expected dryRun=true dump=false, got dryRun=%t dump=%t", options.dryRun, options.dump)
+	}
+	options, err = parseSendArgs([]string{"--dump"})
+	expectNoError(t, err)
+	if !options.dryRun || !options.dump {
+		t.Errorf("expected dryRun=true dump=true, got dryRun=%t dump=%t", options.dryRun, options.dump)
+	}
+	_, err = parseSendArgs([]string{"--output", "yaml"})
+	expectError(t, "invalid value for --output: 'yaml' (should be text or json) (argument 1)", err)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Options that read blocks from a file, or from stdin with "-", by the type of
+// their placeholder block.
+var sourceBlockOptions = map[string]string{
+	markdownSourceBlockType: "--markdown",
+	tableCsvSourceBlockType: "--table-csv",
+	tableTsvSourceBlockType: "--table-tsv",
+	kvJsonSourceBlockType:   "--kv-json",
+}
+
+// readsStdin tells whether block is a placeholder for blocks read from stdin.
+func (block sendBlock) readsStdin() bool {
+	_, ok := sourceBlockOptions[block.blockType]
+	return ok && block.text == "-"
+}
+
+// expandSourceBlocks replaces the placeholders of --markdown, --table-csv,
+// --table-tsv and --kv-json with the blocks parsed from each source. "-" reads the source
+// from stdin. Returns whether stdin was used, in which case it shouldn't be
+// appended to the email again.
+func expandSourceBlocks(options *sendOptions, hasStdin bool, stdin []byte, warnings io.Writer) (bool, error) {
+	usedStdin := false
+	blocks := make([]sendBlock, 0, len(options.blocks))
+	for _, block := range options.blocks {
+		option, ok := sourceBlockOptions[block.blockType]
+		if !ok {
+			blocks = append(blocks, block)
+			continue
+		}
+		path := block.text
+		var source []byte
+		if path == "-" {
+			if !hasStdin || usedStdin {
+				return usedStdin, errors.New(option + " -: nothing was piped into stdin")
+			}
+			source = stdin
+			usedStdin = true
+			path = "stdin"
+		} else {
+			var err error
+			source, err = ioutil.ReadFile(path)
+			if err != nil {
+				return usedStdin, err
+			}
+		}
+
+		switch block.blockType {
+		case markdownSourceBlockType:
+			parsed, parseWarnings := markdownToBlocks(string(source))
+			for _, warning := range parseWarnings {
+				fmt.Fprintln(warnings, "warning: "+path+": "+warning)
+			}
+			if block.text != "-" {
+				resolveMarkdownImages(parsed, filepath.Dir(path))
+			}
+			blocks = append(blocks, parsed...)
+		case kvJsonSourceBlockType:
+			pairs, err := readKvJson(path, source)
+			if err != nil {
+				return usedStdin, err
+			}
+			blocks = append(blocks, sendBlock{
+				blockType: mendsail.BlockTypeKeyValue,
+				pairs:     pairs,
+			})
+		default:
+			comma := ','
+			if block.blockType == tableTsvSourceBlockType {
+				comma = '\t'
+			}
+			headers, rows, err := readDelimitedTable(source, comma)
+			if err != nil {
+				return usedStdin, errors.New(option + " " + path + ": " + err.Error())
+			}
+			blocks = append(blocks, sendBlock{
+				blockType: mendsail.BlockTypeTable,
+				headers:   headers,
+				rows:      rows,
+			})
+		}
+	}
+	options.blocks = blocks
+	return usedStdin, nil
+}
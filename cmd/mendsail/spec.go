@@ -0,0 +1,295 @@
+package main
+
+import (
+	"strings"
+)
+
+// The commands and options are described once here. showHelp and the shell
+// completion scripts are generated from this, and parseSendArgs takes its
+// short aliases and value-less flags from it.
+
+// How the value of an option is completed, besides a fixed list of values.
+const (
+	completeFile    = "file"
+	completeProfile = "profile"
+)
+
+// subOptionSpec describes a key:value argument following a block option.
+type subOptionSpec struct {
+	key string
+	// Shown in help when there are no values to list.
+	arg    string
+	values []string
+}
+
+type optionSpec struct {
+	name  string
+	short string
+	// Shown in help after the name, e.g. "<url> <text>". Empty for options
+	// that don't take a value.
+	arg        string
+	help       string
+	values     []string
+	complete   string
+	subOptions []subOptionSpec
+}
+
+type optionGroup struct {
+	title   string
+	options []optionSpec
+}
+
+type commandSpec struct {
+	name  string
+	help  string
+	usage []string
+	// Completed as the first argument after the command name.
+	subcommands []string
+	// Subcommands that take a setting name, e.g. "config get".
+	settingSubcommands []string
+	groups             []*optionGroup
+}
+
+var styleValues = []string{"success", "warning", "danger", "info"}
+var sizeValues = []string{"small", "medium", "large"}
+
+var profileOption = optionSpec{name: "--profile", arg: "<name>", help: "Use a profile from the config file", complete: completeProfile}
+var apiKeyOption = optionSpec{name: "--api-key", short: "-k", arg: "<string>", help: "API key for authentication (prefer the options below)"}
+var apiKeyFileOption = optionSpec{name: "--api-key-file", arg: "<path>", help: "Read the API key from a file", complete: completeFile}
+var apiKeyStdinOption = optionSpec{name: "--api-key-stdin", help: "Read the API key from the first line of stdin, and the rest as usual"}
+
+var sendingOptionGroup = &optionGroup{"Sending options", []optionSpec{
+	profileOption,
+	apiKeyOption,
+	apiKeyFileOption,
+	apiKeyStdinOption,
+	{name: "--to", short: "-t", arg: "<string>", help: "Recipient email address (repeatable)"},
+	{name: "--cc", arg: "<string>", help: "CC recipient email address (repeatable)"},
+	{name: "--bcc", arg: "<string>", help: "BCC recipient email address (repeatable)"},
+	{name: "--reply-to", arg: "<string>", help: "Reply-To email address"},
+	{name: "--subject", short: "-s", arg: "<string>", help: "Subject line"},
+	{name: "--file", arg: "<path>", help: "Read recipients, subject and blocks from a YAML or JSON file", complete: completeFile},
+	{name: "--dry-run", help: "Print the HTTP request instead of sending it (no API key needed)"},
+	{name: "--output", arg: "<format>", help: "Print the result as text or json (default: text)", values: []string{OutputText, OutputJson}},
+	{name: "--dump", help: "Deprecated alias for --dry-run"},
+	{name: "--preview", arg: "<format>", help: "Render the email as html or text instead of sending it", values: []string{PreviewHtml, PreviewText}},
+	{name: "--preview-file", arg: "<path>", help: "Write the preview to a file (default: stdout)", complete: completeFile},
+}}
+
+var timeoutOption = optionSpec{name: "--timeout", arg: "<duration>", help: "Timeout for each request to the API (default: 30s)"}
+var retriesOption = optionSpec{name: "--retries", arg: "<number>", help: "How many times to retry a failed request (default: 3)"}
+var retryMaxWaitOption = optionSpec{name: "--retry-max-wait", arg: "<duration>", help: "Longest wait between retries (default: 30s)"}
+
+var deliveryOptionGroup = &optionGroup{"Delivery options", []optionSpec{
+	timeoutOption,
+	retriesOption,
+	retryMaxWaitOption,
+	{name: "--spool-on-failure", help: "Queue the email on disk if it can't be sent"},
+}}
+
+var templatingOptionGroup = &optionGroup{"Templating options", []optionSpec{
+	{name: "--var", arg: "<key=value>", help: "Set a template variable (repeatable)"},
+	{name: "--vars-file", arg: "<path>", help: "Read variables from a YAML/JSON mapping or KEY=VALUE lines", complete: completeFile},
+	{name: "--allow-missing-vars", help: "Render undefined variables as empty strings instead of failing"},
+	{name: "--no-templates", help: "Send {{ and }} as is instead of rendering templates"},
+}}
+
+var blockOptionGroup = &optionGroup{"Blocks", []optionSpec{
+	{name: "--alert", arg: "<text>", subOptions: []subOptionSpec{{key: "style", values: styleValues}}},
+	{name: "--button", arg: "<url> <text>", subOptions: []subOptionSpec{{key: "style", values: styleValues}, {key: "ghost", values: []string{"true"}}}},
+	{name: "--code-block", arg: "<text>"},
+	{name: "--divider"},
+	{name: "--heading", arg: "<text>"},
+	{name: "--image", arg: "<url|path>", complete: completeFile, subOptions: []subOptionSpec{{key: "alt", arg: "text"}, {key: "width", arg: "number"}}},
+	{name: "--kv", arg: "<key=value>"},
+	{name: "--kv-json", arg: "<file|->", complete: completeFile},
+	{name: "--link", arg: "<url> [text]"},
+	{name: "--list", arg: "<item1> <item2> ... <itemN> [--end]"},
+	{name: "--markdown", arg: "<file|->", complete: completeFile},
+	{name: "--paragraph", arg: "<text>"},
+	{name: "--quote", arg: "<text>", subOptions: []subOptionSpec{{key: "cite", arg: "source"}}},
+	{name: "--spacer", subOptions: []subOptionSpec{{key: "size", values: sizeValues}}},
+	{name: "--table", arg: "<header1,header2,...>", subOptions: []subOptionSpec{{key: "row", arg: "cell1,cell2,..."}}},
+	{name: "--table-csv", arg: "<file|->", complete: completeFile},
+	{name: "--table-tsv", arg: "<file|->", complete: completeFile},
+}}
+
+var attachmentOptionGroup = &optionGroup{"Attachment options", []optionSpec{
+	{name: "--attach", arg: "<path>", help: "Attach a file (repeatable)", complete: completeFile,
+		subOptions: []subOptionSpec{{key: "name", arg: "filename"}, {key: "type", arg: "mime"}}},
+	{name: "--attach-compress", help: "Gzip text attachments larger than 64 KiB"},
+	{name: "--attach-max-bytes", arg: "<bytes|unlimited>", help: "Limit on the total size of attachments, base64-encoded (default: 10485760)"},
+}}
+
+var stdinOptionGroup = &optionGroup{"Stdin options", []optionSpec{
+	{name: "--stdin-as", arg: "<mode>", help: "Add stdin as code, paragraph, list, markdown or none (default: code)",
+		values: []string{StdinAsCode, StdinAsParagraph, StdinAsList, StdinAsMarkdown, StdinAsNone}},
+	{name: "--stdin-position", arg: "<position>", help: "Add stdin at the start, end or a block index (default: end)",
+		values: []string{StdinPositionStart, StdinPositionEnd}},
+	{name: "--stdin-head", arg: "<lines>", help: "Only include the first N lines"},
+	{name: "--stdin-tail", arg: "<lines>", help: "Only include the last N lines"},
+	{name: "--stdin-max-bytes", arg: "<bytes>", help: "Only include up to N bytes"},
+	{name: "--redact", arg: "<regex>", help: "Replace matches in stdin and command output with [REDACTED] (repeatable)"},
+}}
+
+var runOptionGroup = &optionGroup{"Run options", []optionSpec{
+	{name: "--on-failure-only", help: "Only send email if the command exits with a non-zero status"},
+	{name: "--on-success-only", help: "Only send email if the command exits with status 0"},
+	{name: "--tee", help: "Also pass the command's stdout/stderr through"},
+}}
+
+var otherOptionGroup = &optionGroup{"Other options", []optionSpec{
+	{name: "--help", help: "Show this help message"},
+}}
+
+var loginOptionGroup = &optionGroup{"", []optionSpec{profileOption, apiKeyFileOption, apiKeyStdinOption}}
+var queueOptionGroup = &optionGroup{"", []optionSpec{profileOption, apiKeyOption, apiKeyFileOption, timeoutOption, retriesOption, retryMaxWaitOption}}
+
+// sendOptionGroups are accepted by every command that sends email.
+var sendOptionGroups = []*optionGroup{sendingOptionGroup, deliveryOptionGroup, templatingOptionGroup, blockOptionGroup, attachmentOptionGroup, stdinOptionGroup}
+
+var commandSpecs = []commandSpec{
+	{
+		name:   "send",
+		help:   "Send an email",
+		usage:  []string{"mendsail send <options> <blocks>", "cat file.txt | mendsail send <options> <blocks>"},
+		groups: sendOptionGroups,
+	},
+	{
+		name:   "run",
+		help:   "Run a command and email its output",
+		usage:  []string{"mendsail run <options> <blocks> -- <command> [args...]"},
+		groups: append(append([]*optionGroup{}, sendOptionGroups...), runOptionGroup),
+	},
+	{
+		name:        "queue",
+		help:        "List, send or delete queued emails",
+		usage:       []string{"mendsail queue list|flush|purge [--profile <name>] [--api-key <string>]"},
+		subcommands: []string{"list", "flush", "purge"},
+		groups:      []*optionGroup{queueOptionGroup},
+	},
+	{
+		name:               "config",
+		help:               "Show or edit the config file",
+		usage:              []string{"mendsail config get|set|unset <key> [<value>] [--profile <name>] [--show-secret]", "mendsail config list|profiles|path", "mendsail config explain <options>"},
+		subcommands:        []string{"get", "set", "unset", "list", "profiles", "path", "explain"},
+		settingSubcommands: []string{"get", "set", "unset"},
+		groups:             sendOptionGroups,
+	},
+	{
+		name:   "login",
+		help:   "Store the API key",
+		usage:  []string{"mendsail login|logout [--profile <name>]"},
+		groups: []*optionGroup{loginOptionGroup},
+	},
+	{
+		name:   "logout",
+		help:   "Remove the stored API key",
+		groups: []*optionGroup{{"", []optionSpec{profileOption}}},
+	},
+	{
+		name:        "completion",
+		help:        "Print a shell completion script",
+		usage:       []string{"mendsail completion bash|zsh|fish"},
+		subcommands: completionShells,
+	},
+}
+
+// helpOptionGroups are listed in showHelp, in this order.
+var helpOptionGroups = []*optionGroup{sendingOptionGroup, deliveryOptionGroup, templatingOptionGroup, blockOptionGroup, attachmentOptionGroup, stdinOptionGroup, runOptionGroup, otherOptionGroup}
+
+// options returns the command's options without duplicates.
+func (command *commandSpec) options() []optionSpec {
+	seen := make(map[string]bool)
+	options := make([]optionSpec, 0)
+	for _, group := range command.groups {
+		for _, option := range group.options {
+			if !seen[option.name] {
+				seen[option.name] = true
+				options = append(options, option)
+			}
+		}
+	}
+	return options
+}
+
+func allOptions() []optionSpec {
+	options := make([]optionSpec, 0)
+	for _, group := range helpOptionGroups {
+		options = append(options, group.options...)
+	}
+	return options
+}
+
+func shortOptionAliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, option := range allOptions() {
+		if option.short != "" {
+			aliases[option.short] = option.name
+		}
+	}
+	return aliases
+}
+
+// valuelessOptions returns the options in groups that don't take a value.
+func valuelessOptions(groups []*optionGroup) map[string]bool {
+	flags := make(map[string]bool)
+	for _, group := range groups {
+		for _, option := range group.options {
+			if option.arg == "" {
+				flags[option.name] = true
+			}
+		}
+	}
+	return flags
+}
+
+// displayName is the name shown in help, with the short alias if any.
+func (option *optionSpec) displayName() string {
+	if option.short != "" {
+		return option.name + ", " + option.short
+	}
+	return option.name
+}
+
+// displayArg is the argument shown in help, including sub-options.
+func (option *optionSpec) displayArg() string {
+	parts := []string{}
+	if option.arg != "" {
+		parts = append(parts, option.arg)
+	}
+	for _, subOption := range option.subOptions {
+		value := subOption.arg
+		if len(subOption.values) > 0 {
+			value = strings.Join(subOption.values, "|")
+		}
+		parts = append(parts, "["+subOption.key+":"+value+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeOptionGroup lists the options of group in aligned columns.
+func writeOptionGroup(builder *strings.Builder, group *optionGroup) {
+	nameWidth := 0
+	argWidth := 0
+	for _, option := range group.options {
+		if len(option.displayName()) > nameWidth {
+			nameWidth = len(option.displayName())
+		}
+		if option.help != "" && len(option.displayArg()) > argWidth {
+			argWidth = len(option.displayArg())
+		}
+	}
+	builder.WriteString(group.title + ":\n")
+	for _, option := range group.options {
+		line := "  " + padRight(option.displayName(), nameWidth+2) + padRight(option.displayArg(), argWidth+2) + option.help
+		builder.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+}
+
+func padRight(value string, width int) string {
+	if len(value) >= width {
+		return value
+	}
+	return value + strings.Repeat(" ", width-len(value))
+}
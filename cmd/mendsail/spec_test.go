@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_writeOptionGroup(t *testing.T) {
+	var builder strings.Builder
+	writeOptionGroup(&builder, &optionGroup{"Example options", []optionSpec{
+		{name: "--to", short: "-t", arg: "<string>", help: "Recipient"},
+		{name: "--dry-run", help: "Don't send"},
+		{name: "--alert", arg: "<text>", subOptions: []subOptionSpec{{key: "style", values: []string{"info", "danger"}}}},
+		{name: "--image", arg: "<url>", subOptions: []subOptionSpec{{key: "alt", arg: "text"}}},
+	}})
+	exceptStringsEqual(t, ""+
+		"Example options:\n"+
+		"  --to, -t   <string>  Recipient\n"+
+		"  --dry-run            Don't send\n"+
+		"  --alert    <text> [style:info|danger]\n"+
+		"  --image    <url> [alt:text]\n",
+		builder.String())
+}
+
+func Test_sendOptionGroups_Accepted(t *testing.T) {
+	for _, group := range sendOptionGroups {
+		for _, option := range group.options {
+			args := []string{option.name}
+			if option.arg != "" {
+				args = append(args, "1")
+			}
+			_, err := parseSendArgs(args)
+			if err != nil && strings.Contains(err.Error(), "Unrecognized option") {
+				t.Errorf("%s: %s", option.name, err)
+			}
+		}
+	}
+}
+
+func Test_shortOptionAliases(t *testing.T) {
+	aliases := shortOptionAliases()
+	exceptStringsEqual(t, "--to", aliases["-t"])
+	exceptStringsEqual(t, "--subject", aliases["-s"])
+	exceptStringsEqual(t, "--api-key", aliases["-k"])
+}
+
+func Test_valuelessOptions(t *testing.T) {
+	flags := valuelessOptions(sendOptionGroups)
+	if !flags["--dry-run"] || !flags["--api-key-stdin"] || flags["--to"] {
+		t.Errorf("valuelessOptions: unexpected %v", flags)
+	}
+	if flags["--tee"] {
+		t.Errorf("valuelessOptions: --tee is a run option")
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Emails that couldn't be delivered with --spool-on-failure are written to the
+// spool directory, one JSON file per email, and replayed by "queue flush".
+// The API key is deliberately not stored. Flush resolves the key of the
+// profile the email was queued under, as if it was sent with --profile. Emails
+// that the API rejects during a flush (e.g. an invalid recipient) are moved to
+// the "rejected" subdirectory instead of being retried forever.
+
+const spoolEntryVersion = 1
+
+type spoolEntry struct {
+	Version        int             `json:"version"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Endpoint       string          `json:"endpoint"`
+	Profile        string          `json:"profile,omitempty"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func spoolDir() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "mendsail", "spool"), nil
+}
+
+func writeSpoolEntry(dir string, entry spoolEntry) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	name := entry.CreatedAt.UTC().Format("20060102T150405.000000000Z") + "-" + entry.IdempotencyKey + ".json"
+	path := filepath.Join(dir, name)
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeFileAtomic writes data to path (mode 0600) so that readers either see
+// the whole file or nothing.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// listSpoolFiles returns the spooled emails, oldest first.
+func listSpoolFiles(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func rejectedDir(dir string) string {
+	return filepath.Join(dir, "rejected")
+}
+
+// rejectSpoolFile moves a spooled email that the API won't accept out of the
+// queue.
+func rejectSpoolFile(dir string, path string) error {
+	if err := os.MkdirAll(rejectedDir(dir), 0700); err != nil {
+		return err
+	}
+	return os.Rename(path, filepath.Join(rejectedDir(dir), filepath.Base(path)))
+}
+
+func readSpoolEntry(path string) (*spoolEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := spoolEntry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	if entry.Version != spoolEntryVersion {
+		return nil, errors.New(path + ": unsupported spool file version " + strconv.Itoa(entry.Version))
+	}
+	return &entry, nil
+}
+
+// errSpoolLocked is returned by lockSpool if another process holds the lock.
+var errSpoolLocked = errors.New("the queue is locked by another mendsail process")
+
+// lockSpool takes an exclusive lock on the spool directory, so that
+// concurrent flushes (e.g. overlapping cron runs) don't send an email twice.
+func lockSpool(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, errSpoolLocked
+		}
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func spoolEmail(endpoint string, profile string, idempotencyKey string, message *mendsail.Message) (string, error) {
+	dir, err := spoolDir()
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return writeSpoolEntry(dir, spoolEntry{
+		Version:        spoolEntryVersion,
+		CreatedAt:      time.Now(),
+		Endpoint:       endpoint,
+		Profile:        profile,
+		IdempotencyKey: idempotencyKey,
+		Payload:        payload,
+	})
+}
+
+type queueOptions struct {
+	// The API key and profile selected by the options given to queue.
+	apiKey  string
+	profile string
+	timeout time.Duration
+	retry   mendsail.RetryPolicy
+	// Resolves the API key of another profile. Nil if the key was given
+	// explicitly, in which case it's used for every email.
+	profileApiKey func(profile string) (string, error)
+}
+
+// apiKeyFor returns the API key for an email queued under profile.
+func (options *queueOptions) apiKeyFor(profile string) (string, error) {
+	if profile == "" || profile == options.profile || options.profileApiKey == nil {
+		return options.apiKey, nil
+	}
+	return options.profileApiKey(profile)
+}
+
+func parseQueueArgs(args []string) (*queueOptions, error) {
+	sendOptions, err := parseSendArgs(args)
+	if err != nil {
+		return nil, usageError(err)
+	}
+	if len(sendOptions.blocks) > 0 || len(sendOptions.to) > 0 || sendOptions.subject != "" || sendOptions.spoolOnFailure {
+		return nil, usageError(errors.New("queue only accepts --api-key and delivery options"))
+	}
+	flags := *sendOptions
+	if err := applySettings(sendOptions); err != nil {
+		return nil, err
+	}
+	options := &queueOptions{
+		apiKey:  sendOptions.apiKey,
+		profile: sendOptions.profile,
+		timeout: sendOptions.timeout,
+		retry:   sendOptions.retryPolicy(),
+	}
+	if flags.apiKey == "" && flags.apiKeyFile == "" && !flags.apiKeyStdin {
+		apiKeys := make(map[string]string)
+		options.profileApiKey = func(profile string) (string, error) {
+			if apiKey, ok := apiKeys[profile]; ok {
+				return apiKey, nil
+			}
+			withProfile := flags
+			withProfile.profile = profile
+			if err := applySettings(&withProfile); err != nil {
+				return "", err
+			}
+			apiKeys[profile] = withProfile.apiKey
+			return withProfile.apiKey, nil
+		}
+	}
+	return options, nil
+}
+
+// queueList lists the queued emails, followed by those the API rejected,
+// which stay in the rejected directory until purged.
+func queueList(w io.Writer, dir string) error {
+	paths, err := listSpoolFiles(dir)
+	if err != nil {
+		return err
+	}
+	rejected, err := listSpoolFiles(rejectedDir(dir))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(w, "The queue is empty.")
+	}
+	if err := writeSpoolEntries(w, paths); err != nil {
+		return err
+	}
+	if len(rejected) > 0 {
+		fmt.Fprintf(w, "\nRejected by the API (in %s, removed by \"mendsail queue purge\"):\n", rejectedDir(dir))
+		return writeSpoolEntries(w, rejected)
+	}
+	return nil
+}
+
+func writeSpoolEntries(w io.Writer, paths []string) error {
+	for _, path := range paths {
+		entry, err := readSpoolEntry(path)
+		if err != nil {
+			return err
+		}
+		payload := mendsail.Message{}
+		json.Unmarshal(entry.Payload, &payload)
+		fmt.Fprintf(w, "%s  %s  %s  %s\n",
+			entry.IdempotencyKey,
+			entry.CreatedAt.Local().Format(time.RFC3339),
+			strings.Join(payload.To, ","),
+			payload.Subject)
+	}
+	return nil
+}
+
+func queueFlush(dir string, options queueOptions) error {
+	unlock, err := lockSpool(dir)
+	if err == errSpoolLocked {
+		// The other process is already sending the queue, which is what this
+		// one would do (e.g. overlapping cron runs).
+		fmt.Println("The queue is being flushed by another mendsail process.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	paths, err := listSpoolFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fmt.Println("The queue is empty.")
+		return nil
+	}
+	failed := 0
+	rejected := 0
+	for _, path := range paths {
+		entry, err := readSpoolEntry(path)
+		if err == nil {
+			err = sendSpoolEntry(entry, options)
+		}
+		var apiErr *mendsail.ApiError
+		if errors.As(err, &apiErr) && !isTemporary(err) {
+			rejected++
+			fmt.Fprintln(os.Stderr, filepath.Base(path)+": "+err.Error())
+			if err := rejectSpoolFile(dir, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, filepath.Base(path)+": "+err.Error())
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Sent %d of %d queued emails.\n", len(paths)-failed-rejected, len(paths))
+	if rejected > 0 {
+		fmt.Printf("Moved %d emails rejected by the API to %s.\n", rejected, rejectedDir(dir))
+	}
+	if failed+rejected > 0 {
+		return fmt.Errorf("%d queued emails could not be sent", failed+rejected)
+	}
+	return nil
+}
+
+func sendSpoolEntry(entry *spoolEntry, options queueOptions) error {
+	message := &mendsail.Message{}
+	if err := json.Unmarshal(entry.Payload, message); err != nil {
+		return err
+	}
+	apiKey, err := options.apiKeyFor(entry.Profile)
+	if err != nil {
+		return errors.New("profile " + entry.Profile + ": " + err.Error())
+	}
+	if apiKey == "" {
+		return errors.New("missing option: --api-key")
+	}
+	baseUrl := strings.TrimSuffix(entry.Endpoint, "/emails")
+	client := newApiClient(baseUrl, apiKey, options.timeout, options.retry)
+	_, err = client.Send(context.Background(), message, mendsail.WithIdempotencyKey(entry.IdempotencyKey))
+	return err
+}
+
+func queuePurge(dir string) error {
+	unlock, err := lockSpool(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	paths, err := listSpoolFiles(dir)
+	if err != nil {
+		return err
+	}
+	rejected, err := listSpoolFiles(rejectedDir(dir))
+	if err != nil {
+		return err
+	}
+	for _, path := range append(paths, rejected...) {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Removed %d queued emails.\n", len(paths))
+	if len(rejected) > 0 {
+		fmt.Printf("Removed %d emails rejected by the API.\n", len(rejected))
+	}
+	return nil
+}
+
+func runQueue(args []string) error {
+	if len(args) < 1 {
+		return usageError(errors.New("missing subcommand, usage: mendsail queue list|flush|purge"))
+	}
+	dir, err := spoolDir()
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		return queueList(os.Stdout, dir)
+	case "flush":
+		options, err := parseQueueArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		return queueFlush(dir, *options)
+	case "purge":
+		return queuePurge(dir)
+	default:
+		return usageError(errors.New("unknown subcommand: " + args[0] + ", usage: mendsail queue list|flush|purge"))
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_spoolDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/tmp/state")
+	dir, err := spoolDir()
+	expectNoError(t, err)
+	exceptStringsEqual(t, "/tmp/state/mendsail/spool", dir)
+
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/foobar")
+	dir, err = spoolDir()
+	expectNoError(t, err)
+	exceptStringsEqual(t, "/home/foobar/.local/state/mendsail/spool", dir)
+}
+
+func Test_writeSpoolEntry_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	entry := spoolEntry{
+		Version:        spoolEntryVersion,
+		CreatedAt:      time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC),
+		Endpoint:       "https://api.example.com/v1/emails",
+		IdempotencyKey: "key-123",
+		Payload:        []byte("{\"to\":[\"foobar@example.com\"]}"),
+	}
+	path, err := writeSpoolEntry(dir, entry)
+	expectNoError(t, err)
+	exceptStringsEqual(t, filepath.Join(dir, "20210104T120000.000000000Z-key-123.json"), path)
+
+	stat, err := os.Stat(path)
+	expectNoError(t, err)
+	if stat.Mode().Perm() != 0600 {
+		t.Errorf("mode: expected=%o actual=%o", 0600, stat.Mode().Perm())
+	}
+
+	paths, err := listSpoolFiles(dir)
+	expectNoError(t, err)
+	if len(paths) != 1 || paths[0] != path {
+		t.Fatalf("listSpoolFiles: expected=[%s] actual=%s", path, paths)
+	}
+	actual, err := readSpoolEntry(path)
+	expectNoError(t, err)
+	exceptStringsEqual(t, entry.Endpoint, actual.Endpoint)
+	exceptStringsEqual(t, string(entry.Payload), string(actual.Payload))
+}
+
+func Test_listSpoolFiles_MissingDir(t *testing.T) {
+	paths, err := listSpoolFiles(filepath.Join(t.TempDir(), "missing"))
+	expectNoError(t, err)
+	if len(paths) != 0 {
+		t.Errorf("listSpoolFiles: expected=[] actual=%s", paths)
+	}
+}
+
+func Test_lockSpool_Exclusive(t *testing.T) {
+	dir := t.TempDir()
+	unlock, err := lockSpool(dir)
+	expectNoError(t, err)
+	_, err = lockSpool(dir)
+	expectError(t, "the queue is locked by another mendsail process", err)
+	unlock()
+	unlock, err = lockSpool(dir)
+	expectNoError(t, err)
+	unlock()
+}
+
+func Test_queueFlush(t *testing.T) {
+	keys := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("idempotency-key"))
+		if r.Header.Get("idempotency-key") == "bad" {
+			w.WriteHeader(400)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for i, key := range []string{"good-1", "bad", "good-2"} {
+		_, err := writeSpoolEntry(dir, spoolEntry{
+			Version:        spoolEntryVersion,
+			CreatedAt:      time.Date(2021, 1, 4, 12, 0, i, 0, time.UTC),
+			Endpoint:       server.URL,
+			IdempotencyKey: key,
+			Payload:        []byte("{}"),
+		})
+		expectNoError(t, err)
+	}
+
+	err := queueFlush(dir, queueOptions{apiKey: "api-key-123", timeout: time.Second})
+	expectError(t, "1 queued emails could not be sent", err)
+	if len(keys) != 3 || keys[0] != "good-1" || keys[2] != "good-2" {
+		t.Errorf("sent: expected=[good-1 bad good-2] actual=%s", keys)
+	}
+	paths, err := listSpoolFiles(dir)
+	expectNoError(t, err)
+	if len(paths) != 0 {
+		t.Errorf("remaining: expected=[] actual=%s", paths)
+	}
+	rejected, err := listSpoolFiles(rejectedDir(dir))
+	expectNoError(t, err)
+	if len(rejected) != 1 || filepath.Base(rejected[0]) != "20210104T120001.000000000Z-bad.json" {
+		t.Errorf("rejected: expected only the bad email, actual=%s", rejected)
+	}
+
+	var out bytes.Buffer
+	expectNoError(t, queueList(&out, dir))
+	expected := "The queue is empty.\n" +
+		"\n" +
+		"Rejected by the API (in " + rejectedDir(dir) + ", removed by \"mendsail queue purge\"):\n" +
+		"bad  " + time.Date(2021, 1, 4, 12, 0, 1, 0, time.UTC).Local().Format(time.RFC3339) + "    \n"
+	exceptStringsEqual(t, expected, out.String())
+
+	err = queuePurge(dir)
+	expectNoError(t, err)
+	paths, _ = listSpoolFiles(dir)
+	rejected, _ = listSpoolFiles(rejectedDir(dir))
+	if len(paths) != 0 || len(rejected) != 0 {
+		t.Errorf("remaining after purge: expected=[] actual=%s %s", paths, rejected)
+	}
+}
+
+func Test_queueFlush_KeepsTemporaryFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	_, err := writeSpoolEntry(dir, spoolEntry{
+		Version:        spoolEntryVersion,
+		CreatedAt:      time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC),
+		Endpoint:       server.URL,
+		IdempotencyKey: "key-123",
+		Payload:        []byte("{}"),
+	})
+	expectNoError(t, err)
+	err = queueFlush(dir, queueOptions{apiKey: "api-key-123", timeout: time.Second})
+	expectError(t, "1 queued emails could not be sent", err)
+	paths, _ := listSpoolFiles(dir)
+	if len(paths) != 1 {
+		t.Errorf("remaining: expected the email to stay queued, actual=%s", paths)
+	}
+}
+
+func Test_queueFlush_MissingApiKey(t *testing.T) {
+	dir := t.TempDir()
+	expectNoError(t, queueFlush(dir, queueOptions{}))
+	_, err := writeSpoolEntry(dir, spoolEntry{Version: spoolEntryVersion, IdempotencyKey: "key-123", Payload: []byte("{}")})
+	expectNoError(t, err)
+	err = queueFlush(dir, queueOptions{})
+	expectError(t, "1 queued emails could not be sent", err)
+}
+
+func Test_queueFlush_Locked(t *testing.T) {
+	dir := t.TempDir()
+	_, err := writeSpoolEntry(dir, spoolEntry{Version: spoolEntryVersion, IdempotencyKey: "key-123", Payload: []byte("{}")})
+	expectNoError(t, err)
+	unlock, err := lockSpool(dir)
+	expectNoError(t, err)
+	defer unlock()
+	expectNoError(t, queueFlush(dir, queueOptions{}))
+	paths, _ := listSpoolFiles(dir)
+	if len(paths) != 1 {
+		t.Errorf("remaining: expected the email to stay queued, actual=%s", paths)
+	}
+}
+
+func Test_parseQueueArgs(t *testing.T) {
+	t.Setenv("MENDSAIL_API_KEY", "")
+	options, err := parseQueueArgs([]string{"--api-key", "api-key-123", "--retries", "1"})
+	expectNoError(t, err)
+	exceptStringsEqual(t, "api-key-123", options.apiKey)
+	if options.retry.Retries != 1 {
+		t.Errorf("retries: expected=%d actual=%d", 1, options.retry.Retries)
+	}
+	_, err = parseQueueArgs([]string{"--to", "foobar@example.com"})
+	expectError(t, "queue only accepts --api-key and delivery options", err)
+	expectExitCode(t, 2, err)
+	_, err = parseQueueArgs([]string{"--spool-on-failure"})
+	expectError(t, "queue only accepts --api-key and delivery options", err)
+
+	writeConfig(t, testConfig)
+	_, err = parseQueueArgs([]string{"--profile", "foobar"})
+	if err == nil || exitCodeFor(err) == 2 {
+		t.Errorf("unknown profile: expected a non-usage error, actual=%v", err)
+	}
+}
+
+func Test_runQueue_UnknownSubcommand(t *testing.T) {
+	err := runQueue([]string{"foobar"})
+	expectError(t, "unknown subcommand: foobar, usage: mendsail queue list|flush|purge", err)
+	err = runQueue([]string{})
+	expectError(t, "missing subcommand, usage: mendsail queue list|flush|purge", err)
+}
+
+func Test_deliverEmail_SpoolsOnlyTemporaryFailures(t *testing.T) {
+	status := 400
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir, err := spoolDir()
+	expectNoError(t, err)
+
+	options := sendOptions{
+		baseUrl:        server.URL,
+		apiKey:         "api-key-123",
+		to:             []string{"foo@example.com"},
+		subject:        "Test",
+		retries:        0,
+		spoolOnFailure: true,
+	}
+	err = deliverEmail(options)
+	expectExitCode(t, 3, err)
+	paths, _ := listSpoolFiles(dir)
+	if len(paths) != 0 {
+		t.Errorf("queued after a 400: expected=[] actual=%s", paths)
+	}
+
+	status = 503
+	expectNoError(t, deliverEmail(options))
+	paths, _ = listSpoolFiles(dir)
+	if len(paths) != 1 {
+		t.Errorf("queued after a 503: expected one email, actual=%s", paths)
+	}
+}
+
+func Test_queueFlush_UsesQueuedProfile(t *testing.T) {
+	t.Setenv("MENDSAIL_API_KEY", "")
+	t.Setenv("MENDSAIL_PROFILE", "")
+	writeConfig(t, testConfig)
+	keys := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys[r.Header.Get("idempotency-key")] = r.Header.Get("x-api-key")
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for i, profile := range []string{"production", "staging", ""} {
+		_, err := writeSpoolEntry(dir, spoolEntry{
+			Version:        spoolEntryVersion,
+			CreatedAt:      time.Date(2021, 1, 4, 12, 0, i, 0, time.UTC),
+			Endpoint:       server.URL,
+			Profile:        profile,
+			IdempotencyKey: "queued-" + profile,
+			Payload:        []byte("{}"),
+		})
+		expectNoError(t, err)
+	}
+
+	options, err := parseQueueArgs([]string{"--profile", "staging"})
+	expectNoError(t, err)
+	expectNoError(t, queueFlush(dir, *options))
+	expected := map[string]string{
+		"queued-production": "prod-key",
+		"queued-staging":    "staging-key",
+		"queued-":           "staging-key",
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("keys: expected=%s actual=%s", expected, keys)
+	}
+}
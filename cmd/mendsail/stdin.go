@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+const (
+	StdinAsCode      = "code"
+	StdinAsParagraph = "paragraph"
+	StdinAsList      = "list"
+	StdinAsMarkdown  = "markdown"
+	StdinAsNone      = "none"
+)
+
+const (
+	StdinPositionStart = "start"
+	StdinPositionEnd   = "end"
+)
+
+type stdinLimits struct {
+	head     int
+	tail     int
+	maxBytes int
+}
+
+// stdinContent holds the lines that will be sent: the first lines (head), the
+// last lines (tail) and how many lines in between were left out.
+type stdinContent struct {
+	head    []string
+	tail    []string
+	omitted int
+}
+
+func validateStdinAs(value string) error {
+	switch value {
+	case StdinAsCode, StdinAsParagraph, StdinAsList, StdinAsMarkdown, StdinAsNone:
+		return nil
+	}
+	return errors.New("invalid value for --stdin-as: '" + value + "' (should be one of: code, paragraph, list, markdown, none)")
+}
+
+func validateStdinPosition(value string) error {
+	if value == StdinPositionStart || value == StdinPositionEnd {
+		return nil
+	}
+	if index, err := strconv.Atoi(value); err == nil && index >= 0 {
+		return nil
+	}
+	return errors.New("invalid value for --stdin-position: '" + value + "' (should be start, end or a block index)")
+}
+
+func parseNonNegativeInt(option string, value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, errors.New("invalid value for " + option + ": '" + value + "' (should be a non-negative integer)")
+	}
+	return number, nil
+}
+
+func stdinIsPiped() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) == 0
+}
+
+func readStdin(limits stdinLimits, filter *outputFilter) (bool, *stdinContent, error) {
+	if !stdinIsPiped() {
+		return false, &stdinContent{}, nil
+	}
+	content, err := readLimitedLines(os.Stdin, limits, filter.newLineRedactor().filterLine)
+	return true, content, err
+}
+
+// readLimitedLines reads r line by line without a limit on line length, but
+// only keeps what will be sent: the first limits.head lines, the last
+// limits.tail lines (in a ring buffer) and, if limits.maxBytes is set, no
+// more than that many bytes in total. With neither head nor tail set, lines
+// are kept from the start. Each line is passed through filterLine (if not nil)
+// before it's counted, so that secrets are redacted before anything is cut.
+func readLimitedLines(r io.Reader, limits stdinLimits, filterLine func(string) (string, int)) (*stdinContent, error) {
+	content := &stdinContent{
+		head: make([]string, 0),
+		tail: make([]string, 0),
+	}
+	keepAll := limits.head == 0 && limits.tail == 0
+	initialBudget := limits.maxBytes
+	if initialBudget == 0 {
+		initialBudget = -1
+	}
+	budget := initialBudget
+	// Leave room for escape sequences that filterLine may remove, and for a
+	// secret that crosses the cut to still be matched as a whole.
+	readMaxBytes := 0
+	if limits.maxBytes > 0 {
+		readMaxBytes = limits.maxBytes*4 + 4096
+	}
+
+	var ring []string
+	ringStart := 0
+	if limits.tail > 0 {
+		ring = make([]string, 0, limits.tail)
+	}
+
+	reader := bufio.NewReader(r)
+	total := 0
+	headSeen := 0
+	cutLines := 0
+	for {
+		line, err := readLine(reader, readMaxBytes)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return content, err
+		}
+		if filterLine != nil {
+			var action int
+			line, action = filterLine(line)
+			if action == lineDrop {
+				continue
+			}
+		}
+		total++
+
+		if keepAll || headSeen < limits.head {
+			headSeen++
+			if budget < 0 || len(line)+1 <= budget {
+				content.head = append(content.head, line)
+				if budget >= 0 {
+					budget -= len(line) + 1
+				}
+			} else if len(content.head) == 0 && budget > 0 {
+				// A single line longer than the whole budget is cut rather
+				// than dropped, so that something gets sent.
+				content.head = append(content.head, truncateUtf8(line, budget-1))
+				budget = 0
+				cutLines++
+			} else {
+				// Don't let a shorter line after this one leave a gap.
+				budget = 0
+			}
+			continue
+		}
+		if limits.tail > 0 {
+			if budget >= 0 && len(line) > initialBudget {
+				// Too long to be kept anyway; don't hold on to all of it.
+				line = line[:initialBudget]
+			}
+			if len(ring) < limits.tail {
+				ring = append(ring, line)
+			} else {
+				ring[ringStart] = line
+				ringStart = (ringStart + 1) % limits.tail
+			}
+		}
+	}
+
+	for i := 0; i < len(ring); i++ {
+		content.tail = append(content.tail, ring[(ringStart+i)%len(ring)])
+	}
+	if budget >= 0 {
+		// Keep the end of the tail, since that's what the tail is for.
+		used := 0
+		keepFrom := len(content.tail)
+		for keepFrom > 0 && used+len(content.tail[keepFrom-1])+1 <= budget {
+			used += len(content.tail[keepFrom-1]) + 1
+			keepFrom--
+		}
+		content.tail = content.tail[keepFrom:]
+	}
+	content.omitted = total - len(content.head) - len(content.tail) + cutLines
+	return content, nil
+}
+
+// readLine reads a whole line regardless of its length, keeping at most
+// maxBytes (if non-zero) of it. The trailing newline is not included.
+func readLine(reader *bufio.Reader, maxBytes int) (string, error) {
+	var builder strings.Builder
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if maxBytes == 0 || builder.Len() < maxBytes {
+			keep := chunk
+			if maxBytes > 0 && builder.Len()+len(keep) > maxBytes {
+				keep = keep[:maxBytes-builder.Len()]
+			}
+			builder.Write(keep)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(chunk) == 0 && builder.Len() == 0 {
+			return "", io.EOF
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(builder.String(), "\n"), "\r"), nil
+	}
+}
+
+func truncateUtf8(value string, maxBytes int) string {
+	if maxBytes < 0 {
+		return ""
+	}
+	if len(value) <= maxBytes {
+		return value
+	}
+	for maxBytes > 0 && !utf8.RuneStart(value[maxBytes]) {
+		maxBytes--
+	}
+	return value[:maxBytes]
+}
+
+func (content *stdinContent) marker() string {
+	if content.omitted == 1 {
+		return "… 1 line truncated …"
+	}
+	return fmt.Sprintf("… %d lines truncated …", content.omitted)
+}
+
+func (content *stdinContent) lines() []string {
+	lines := append([]string{}, content.head...)
+	if content.omitted > 0 {
+		lines = append(lines, content.marker())
+	}
+	return append(lines, content.tail...)
+}
+
+func (content *stdinContent) text() string {
+	lines := content.lines()
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// stdinToBlocks converts stdin into blocks according to --stdin-as.
+func stdinToBlocks(stdinAs string, content *stdinContent, warnings io.Writer) []sendBlock {
+	switch stdinAs {
+	case StdinAsNone:
+		return nil
+	case StdinAsParagraph:
+		return []sendBlock{{blockType: mendsail.BlockTypeParagraph, text: strings.TrimRight(content.text(), "\n")}}
+	case StdinAsList:
+		return []sendBlock{{blockType: mendsail.BlockTypeList, items: content.lines()}}
+	case StdinAsMarkdown:
+		blocks, markdownWarnings := markdownToBlocks(content.text())
+		for _, warning := range markdownWarnings {
+			fmt.Fprintln(warnings, "warning: stdin: "+warning)
+		}
+		return blocks
+	default:
+		return []sendBlock{{blockType: mendsail.BlockTypeCodeBlock, text: content.text()}}
+	}
+}
+
+// insertStdinBlocks places blocks according to --stdin-position.
+func insertStdinBlocks(options *sendOptions, blocks []sendBlock) error {
+	index := len(options.blocks)
+	switch options.stdinPosition {
+	case "", StdinPositionEnd:
+	case StdinPositionStart:
+		index = 0
+	default:
+		index, _ = strconv.Atoi(options.stdinPosition)
+		if index > len(options.blocks) {
+			return fmt.Errorf("--stdin-position %d is out of range (the email has %d blocks)", index, len(options.blocks))
+		}
+	}
+	inserted := make([]sendBlock, 0, len(options.blocks)+len(blocks))
+	inserted = append(inserted, options.blocks[:index]...)
+	inserted = append(inserted, blocks...)
+	options.blocks = append(inserted, options.blocks[index:]...)
+	return nil
+}
+
+func (options *sendOptions) stdinLimits() stdinLimits {
+	return stdinLimits{
+		head:     options.stdinHead,
+		tail:     options.stdinTail,
+		maxBytes: options.stdinMaxBytes,
+	}
+}
+
+// wantsStdin tells whether stdin should be read at all.
+func (options *sendOptions) wantsStdin() bool {
+	if options.stdinAs != StdinAsNone {
+		return true
+	}
+	for _, block := range options.blocks {
+		if block.readsStdin() {
+			return true
+		}
+	}
+	return false
+}
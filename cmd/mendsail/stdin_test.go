@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func expectStdinContent(t *testing.T, expected stdinContent, actual *stdinContent) {
+	if !reflect.DeepEqual(expected.head, actual.head) {
+		t.Errorf("head: expected=%q actual=%q", expected.head, actual.head)
+	}
+	if !reflect.DeepEqual(expected.tail, actual.tail) {
+		t.Errorf("tail: expected=%q actual=%q", expected.tail, actual.tail)
+	}
+	if expected.omitted != actual.omitted {
+		t.Errorf("omitted: expected=%d actual=%d", expected.omitted, actual.omitted)
+	}
+}
+
+func Test_readLimitedLines_All(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("a\nb\r\nc"), stdinLimits{}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"a", "b", "c"}, tail: []string{}}, content)
+	exceptStringsEqual(t, "a\nb\nc\n", content.text())
+}
+
+func Test_readLimitedLines_LongLine(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	content, err := readLimitedLines(strings.NewReader(long+"\nshort\n"), stdinLimits{}, nil)
+	expectNoError(t, err)
+	if len(content.head) != 2 || content.head[0] != long {
+		t.Errorf("head: expected a %d byte line, actual=%d lines", len(long), len(content.head))
+	}
+}
+
+func Test_readLimitedLines_Head(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("1\n2\n3\n4\n5\n"), stdinLimits{head: 2}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"1", "2"}, tail: []string{}, omitted: 3}, content)
+	exceptStringsEqual(t, "1\n2\n… 3 lines truncated …\n", content.text())
+}
+
+func Test_readLimitedLines_Tail(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("1\n2\n3\n4\n5\n"), stdinLimits{tail: 2}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{}, tail: []string{"4", "5"}, omitted: 3}, content)
+	exceptStringsEqual(t, "… 3 lines truncated …\n4\n5\n", content.text())
+}
+
+func Test_readLimitedLines_HeadAndTail(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("1\n2\n3\n4\n5\n6\n"), stdinLimits{head: 2, tail: 3}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"1", "2"}, tail: []string{"4", "5", "6"}, omitted: 1}, content)
+	exceptStringsEqual(t, "1\n2\n… 1 line truncated …\n4\n5\n6\n", content.text())
+}
+
+func Test_readLimitedLines_HeadAndTailOverlap(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("1\n2\n3\n"), stdinLimits{head: 2, tail: 5}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"1", "2"}, tail: []string{"3"}}, content)
+}
+
+func Test_readLimitedLines_MaxBytes(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("aaa\nbbb\nccc\n"), stdinLimits{maxBytes: 9}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"aaa", "bbb"}, tail: []string{}, omitted: 1}, content)
+
+	content, err = readLimitedLines(strings.NewReader("aaa\nbbb\nccc\n"), stdinLimits{tail: 3, maxBytes: 9}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{}, tail: []string{"bbb", "ccc"}, omitted: 1}, content)
+}
+
+func Test_readLimitedLines_MaxBytesCutsSingleLine(t *testing.T) {
+	content, err := readLimitedLines(strings.NewReader("äääää\n"), stdinLimits{maxBytes: 6}, nil)
+	expectNoError(t, err)
+	expectStdinContent(t, stdinContent{head: []string{"ää"}, tail: []string{}, omitted: 1}, content)
+}
+
+func Test_stdinToBlocks(t *testing.T) {
+	content := &stdinContent{head: []string{"- a", "- b"}, tail: []string{}}
+	var warnings bytes.Buffer
+	expected := sendOptions{
+		blocks: []sendBlock{
+			sendBlock{blockType: "CodeBlock", text: "- a\n- b\n"},
+			sendBlock{blockType: "Paragraph", text: "- a\n- b"},
+			sendBlock{blockType: "List", items: []string{"- a", "- b"}},
+			sendBlock{blockType: "List", items: []string{"a", "b"}},
+		},
+	}
+	blocks := make([]sendBlock, 0)
+	for _, stdinAs := range []string{"", "paragraph", "list", "markdown", "none"} {
+		blocks = append(blocks, stdinToBlocks(stdinAs, content, &warnings)...)
+	}
+	exceptOptions(t, expected, &sendOptions{blocks: blocks}, nil)
+}
+
+func Test_insertStdinBlocks(t *testing.T) {
+	stdinBlocks := []sendBlock{sendBlock{blockType: "CodeBlock", text: "stdin"}}
+	tests := []struct {
+		position string
+		expected []string
+	}{
+		{"", []string{"a", "b", "stdin"}},
+		{"end", []string{"a", "b", "stdin"}},
+		{"start", []string{"stdin", "a", "b"}},
+		{"1", []string{"a", "stdin", "b"}},
+		{"2", []string{"a", "b", "stdin"}},
+	}
+	for _, test := range tests {
+		options := sendOptions{
+			stdinPosition: test.position,
+			blocks: []sendBlock{
+				sendBlock{blockType: "Paragraph", text: "a"},
+				sendBlock{blockType: "Paragraph", text: "b"},
+			},
+		}
+		err := insertStdinBlocks(&options, stdinBlocks)
+		expectNoError(t, err)
+		actual := make([]string, 0)
+		for _, block := range options.blocks {
+			actual = append(actual, block.text)
+		}
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("position %s: expected=%s actual=%s", test.position, test.expected, actual)
+		}
+	}
+
+	options := sendOptions{stdinPosition: "3", blocks: []sendBlock{}}
+	err := insertStdinBlocks(&options, stdinBlocks)
+	expectError(t, "--stdin-position 3 is out of range (the email has 0 blocks)", err)
+}
+
+func Test_parseSendArgs_StdinOptions(t *testing.T) {
+	args := []string{
+		"--stdin-as", "list",
+		"--stdin-position", "2",
+		"--stdin-head", "10",
+		"--stdin-tail", "20",
+		"--stdin-max-bytes", "4096",
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "list", actual.stdinAs)
+	exceptStringsEqual(t, "2", actual.stdinPosition)
+	expected := stdinLimits{head: 10, tail: 20, maxBytes: 4096}
+	if actual.stdinLimits() != expected {
+		t.Errorf("stdinLimits: expected=%v actual=%v", expected, actual.stdinLimits())
+	}
+}
+
+func Test_parseSendArgs_InvalidStdinOptions(t *testing.T) {
+	_, err := parseSendArgs([]string{"--stdin-as", "html"})
+	expectError(t, "invalid value for --stdin-as: 'html' (should be one of: code, paragraph, list, markdown, none) (argument 1)", err)
+	_, err = parseSendArgs([]string{"--stdin-position", "middle"})
+	expectError(t, "invalid value for --stdin-position: 'middle' (should be start, end or a block index) (argument 1)", err)
+	_, err = parseSendArgs([]string{"--stdin-tail", "-1"})
+	expectError(t, "invalid value for --stdin-tail: '-1' (should be a non-negative integer) (argument 1)", err)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+// Tables are given on the command line as comma-separated rows, with the same
+// quoting as CSV so that a cell can contain a comma:
+//
+//	--table Host,Disk row:web-1,91% row:'"db-1, replica",45%'
+//
+// --table-csv and --table-tsv read the header row and the rows from a file
+// (or stdin) instead, and are kept as placeholders until expandSourceBlocks.
+const (
+	tableCsvSourceBlockType = "table-csv"
+	tableTsvSourceBlockType = "table-tsv"
+)
+
+// parseTableRow splits one comma-separated row into cells.
+func parseTableRow(value string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(value))
+	reader.LazyQuotes = true
+	row, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty table row")
+	}
+	if err != nil {
+		return nil, errors.New("could not parse table row: " + csvErrorMessage(err))
+	}
+	return row, nil
+}
+
+func validateTableRow(headers []string, row []string) error {
+	if len(row) != len(headers) {
+		return fmt.Errorf("table row has %d columns, expected %d (one per header)", len(row), len(headers))
+	}
+	return nil
+}
+
+// readDelimitedTable parses CSV (comma ',') or TSV (comma '\t') with the
+// header row first.
+func readDelimitedTable(source []byte, comma rune) ([]string, [][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(source))
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	// Checked below for a better error message.
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, errors.New(csvErrorMessage(err))
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("missing header row")
+	}
+	headers := records[0]
+	rows := records[1:]
+	for i, row := range rows {
+		if err := validateTableRow(headers, row); err != nil {
+			return nil, nil, errors.New("line " + strconv.Itoa(i+2) + ": " + err.Error())
+		}
+	}
+	return headers, rows, nil
+}
+
+func csvErrorMessage(err error) string {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return "line " + strconv.Itoa(parseErr.Line) + ": " + parseErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// renderTableText lays out a table in aligned columns for plain text.
+func renderTableText(headers []string, rows [][]string) string {
+	widths := make([]int, len(headers))
+	for _, row := range append([][]string{headers}, rows...) {
+		for i, cell := range row {
+			if i < len(widths) && len([]rune(cell)) > widths[i] {
+				widths[i] = len([]rune(cell))
+			}
+		}
+	}
+	var builder strings.Builder
+	writeRow := func(row []string) {
+		line := ""
+		for i, cell := range row {
+			if i < len(widths) {
+				cell += strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			}
+			line += cell + "  "
+		}
+		builder.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	writeRow(headers)
+	separator := make([]string, len(headers))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	writeRow(separator)
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return builder.String()
+}
+
+// writeTableFallbacks prints the tables of message as plain text after a
+// --dry-run, since rows are hard to read in the JSON payload.
+func writeTableFallbacks(w io.Writer, message *mendsail.Message) {
+	for i, block := range message.Blocks {
+		if block.BlockType != mendsail.BlockTypeTable {
+			continue
+		}
+		fmt.Fprintf(w, "\nBlock %d (%s) as plain text:\n%s", i+1, block.BlockType, renderTableText(block.Headers, block.Rows))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codeclown/mendsail-cli/mendsail"
+)
+
+func Test_parseSendArgs_Table(t *testing.T) {
+	args := []string{
+		"--table", "Host,Disk", "row:web-1,91%", `row:"db-1, replica",45%`,
+		"--paragraph", "after",
+	}
+	expected := sendOptions{
+		blocks: []sendBlock{
+			{blockType: "Table", headers: []string{"Host", "Disk"}, rows: [][]string{{"web-1", "91%"}, {"db-1, replica", "45%"}}},
+			{blockType: "Paragraph", text: "after"},
+		},
+	}
+	actual, err := parseSendArgs(args)
+	exceptOptions(t, expected, actual, err)
+}
+
+func Test_parseSendArgs_TableErrors(t *testing.T) {
+	_, err := parseSendArgs([]string{"--table", "Host,Disk", "row:web-1,91%", "row:db-1"})
+	expectError(t, "table row has 1 columns, expected 2 (one per header) (argument 4)", err)
+	_, err = parseSendArgs([]string{"--table", "Host,Disk", "rows:web-1,91%"})
+	expectError(t, "unknown option: 'rows:web-1,91%' (argument 3)", err)
+	_, err = parseSendArgs([]string{"--table", ""})
+	expectError(t, "empty table row (argument 1)", err)
+}
+
+func Test_readDelimitedTable(t *testing.T) {
+	headers, rows, err := readDelimitedTable([]byte("Host,Disk\nweb-1,91%\n\"db-1, replica\",45%\n"), ',')
+	expectNoError(t, err)
+	exceptStringsEqual(t, "Host Disk", headers[0]+" "+headers[1])
+	if len(rows) != 2 || rows[1][0] != "db-1, replica" {
+		t.Errorf("rows: unexpected %q", rows)
+	}
+
+	headers, rows, err = readDelimitedTable([]byte("Job\tDuration\nbackup\t1m\n"), '\t')
+	expectNoError(t, err)
+	exceptStringsEqual(t, "Duration", headers[1])
+	exceptStringsEqual(t, "1m", rows[0][1])
+
+	_, _, err = readDelimitedTable([]byte("Host,Disk\nweb-1\n"), ',')
+	expectError(t, "line 2: table row has 1 columns, expected 2 (one per header)", err)
+	_, _, err = readDelimitedTable([]byte(""), ',')
+	expectError(t, "missing header row", err)
+}
+
+func Test_expandSourceBlocks_Tables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.tsv")
+	expectNoError(t, os.WriteFile(path, []byte("Job\tDuration\nbackup\t1m\n"), 0644))
+	options := sendOptions{
+		blocks: []sendBlock{
+			{blockType: tableTsvSourceBlockType, text: path},
+			{blockType: tableCsvSourceBlockType, text: "-"},
+		},
+	}
+	usedStdin, err := expandSourceBlocks(&options, true, []byte("Host,Disk\nweb-1,91%\n"), &bytes.Buffer{})
+	expectNoError(t, err)
+	if !usedStdin {
+		t.Errorf("usedStdin: expected=true actual=false")
+	}
+	expected := sendOptions{
+		blocks: []sendBlock{
+			{blockType: "Table", headers: []string{"Job", "Duration"}, rows: [][]string{{"backup", "1m"}}},
+			{blockType: "Table", headers: []string{"Host", "Disk"}, rows: [][]string{{"web-1", "91%"}}},
+		},
+	}
+	exceptOptions(t, expected, &options, nil)
+
+	options = sendOptions{blocks: []sendBlock{{blockType: tableCsvSourceBlockType, text: "-"}}}
+	_, err = expandSourceBlocks(&options, true, []byte("Host,Disk\nweb-1\n"), &bytes.Buffer{})
+	expectError(t, "--table-csv stdin: line 2: table row has 1 columns, expected 2 (one per header)", err)
+	_, err = expandSourceBlocks(&options, false, nil, &bytes.Buffer{})
+	expectError(t, "--table-csv -: nothing was piped into stdin", err)
+}
+
+func Test_renderTableText(t *testing.T) {
+	actual := renderTableText([]string{"Host", "Disk"}, [][]string{{"web-1", "91%"}, {"db", "100%"}})
+	exceptStringsEqual(t, ""+
+		"Host   Disk\n"+
+		"-----  ----\n"+
+		"web-1  91%\n"+
+		"db     100%\n",
+		actual)
+}
+
+func Test_writeTableFallbacks(t *testing.T) {
+	var buffer bytes.Buffer
+	writeTableFallbacks(&buffer, &mendsail.Message{Blocks: []mendsail.Block{
+		mendsail.Heading("Disk usage"),
+		mendsail.Table([]string{"Host", "Disk"}, []string{"web-1", "91%"}),
+	}})
+	exceptStringsEqual(t, "\n"+
+		"Block 2 (Table) as plain text:\n"+
+		"Host   Disk\n"+
+		"-----  ----\n"+
+		"web-1  91%\n",
+		buffer.String())
+}
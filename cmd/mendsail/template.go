@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Subjects and block contents are rendered as text/template templates, with
+// --var and --vars-file values as the data, e.g. "Backup failed on {{.host}}".
+// Text without "{{" is left as is, and --no-templates turns rendering off so
+// that a literal "{{" can be sent. Stdin and the output of run are added only
+// after rendering, so they are never treated as templates.
+
+func templateFuncs(allowMissing bool) template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string) (string, error) {
+			value, ok := os.LookupEnv(name)
+			if !ok && !allowMissing {
+				return "", errors.New("environment variable " + name + " is not set")
+			}
+			return value, nil
+		},
+		"now": time.Now,
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+}
+
+func renderTemplate(name string, text string, vars map[string]string, allowMissing bool) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	missingKey := "missingkey=error"
+	if allowMissing {
+		missingKey = "missingkey=zero"
+	}
+	tmpl, err := template.New(name).Option(missingKey).Funcs(templateFuncs(allowMissing)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func renderSendTemplates(options *sendOptions, vars map[string]string) error {
+	render := func(name string, text *string) error {
+		rendered, err := renderTemplate(name, *text, vars, options.allowMissingVars)
+		if err != nil {
+			return validationError(errors.New("could not render template: " + err.Error()))
+		}
+		*text = rendered
+		return nil
+	}
+
+	if err := render("subject-prefix", &options.subjectPrefix); err != nil {
+		return err
+	}
+	if err := render("subject", &options.subject); err != nil {
+		return err
+	}
+	for i := range options.blocks {
+		block := &options.blocks[i]
+		prefix := "blocks[" + strconv.Itoa(i) + "]"
+		if err := render(prefix+".text", &block.text); err != nil {
+			return err
+		}
+		if err := render(prefix+".url", &block.url); err != nil {
+			return err
+		}
+		if err := render(prefix+".alt", &block.alt); err != nil {
+			return err
+		}
+		if err := render(prefix+".cite", &block.cite); err != nil {
+			return err
+		}
+		for k := range block.items {
+			if err := render(prefix+".items["+strconv.Itoa(k)+"]", &block.items[k]); err != nil {
+				return err
+			}
+		}
+		for k := range block.headers {
+			if err := render(prefix+".headers["+strconv.Itoa(k)+"]", &block.headers[k]); err != nil {
+				return err
+			}
+		}
+		for k := range block.pairs {
+			if err := render(prefix+".pairs["+strconv.Itoa(k)+"].value", &block.pairs[k].Value); err != nil {
+				return err
+			}
+		}
+		for r, row := range block.rows {
+			for k := range row {
+				if err := render(prefix+".rows["+strconv.Itoa(r)+"]["+strconv.Itoa(k)+"]", &row[k]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// loadVarsFile reads variables from a flat YAML or JSON mapping, or from
+// KEY=VALUE lines for any other file extension.
+func loadVarsFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+
+	if strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		root, err := parseDocument(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if root.kind != docMap {
+			return nil, &docError{file: path, line: root.line, msg: "expected a mapping of variable names to values"}
+		}
+		for _, key := range root.keys {
+			value, err := docString(root.fields[key])
+			if err != nil {
+				return nil, &docError{file: path, line: root.fields[key].line, msg: "'" + key + "' " + err.Error()}
+			}
+			vars[key] = value
+		}
+		return vars, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, err := parseVar(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, number, err)
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+func parseVar(value string) (string, string, error) {
+	separator := strings.Index(value, "=")
+	if separator < 1 {
+		return "", "", errors.New("invalid variable '" + value + "' (should be key=value)")
+	}
+	return strings.TrimSpace(value[:separator]), value[separator+1:], nil
+}
+
+// applyTemplates renders subject and blocks. Variables given with --var take
+// precedence over those read from --vars-file.
+func applyTemplates(options *sendOptions) error {
+	if options.noTemplates {
+		return nil
+	}
+	vars := make(map[string]string)
+	for _, path := range options.varsFiles {
+		fromFile, err := loadVarsFile(path)
+		if err != nil {
+			return err
+		}
+		for key, value := range fromFile {
+			vars[key] = value
+		}
+	}
+	for key, value := range options.vars {
+		vars[key] = value
+	}
+	return renderSendTemplates(options, vars)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_renderTemplate_Variables(t *testing.T) {
+	vars := map[string]string{"host": "host-1"}
+	actual, err := renderTemplate("subject", "Backup failed on {{.host}}", vars, false)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "Backup failed on host-1", actual)
+}
+
+func Test_renderTemplate_NoTemplate(t *testing.T) {
+	actual, err := renderTemplate("subject", "plain {text}", nil, false)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "plain {text}", actual)
+}
+
+func Test_renderTemplate_Functions(t *testing.T) {
+	t.Setenv("MENDSAIL_TEST_JOB", "nightly")
+	actual, err := renderTemplate("subject", "{{env \"MENDSAIL_TEST_JOB\"}} {{now | date \"2006\"}}", nil, false)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "nightly "+time.Now().Format("2006"), actual)
+}
+
+func Test_renderTemplate_MissingVariable(t *testing.T) {
+	_, err := renderTemplate("subject", "{{.host}}", map[string]string{}, false)
+	expectError(t, "template: subject:1:2: executing \"subject\" at <.host>: map has no entry for key \"host\"", err)
+	actual, err := renderTemplate("subject", "[{{.host}}]", map[string]string{}, true)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "[]", actual)
+}
+
+func Test_renderTemplate_MissingEnv(t *testing.T) {
+	_, err := renderTemplate("subject", "{{env \"MENDSAIL_TEST_UNSET\"}}", nil, false)
+	expectError(t, "template: subject:1:2: executing \"subject\" at <env \"MENDSAIL_TEST_UNSET\">: error calling env: environment variable MENDSAIL_TEST_UNSET is not set", err)
+	actual, err := renderTemplate("subject", "{{env \"MENDSAIL_TEST_UNSET\"}}", nil, true)
+	expectNoError(t, err)
+	exceptStringsEqual(t, "", actual)
+}
+
+func Test_renderSendTemplates_Blocks(t *testing.T) {
+	options := sendOptions{
+		subject: "{{.job}} failed",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "on {{.host}}"},
+			sendBlock{blockType: "List", items: []string{"{{.job}}", "{{.host}}"}},
+			sendBlock{blockType: "Image", url: "https://example.com/{{.job}}.png", alt: "{{.job}}"},
+		},
+	}
+	vars := map[string]string{"job": "backup", "host": "host-1"}
+	err := renderSendTemplates(&options, vars)
+	expected := sendOptions{
+		subject: "backup failed",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "on host-1"},
+			sendBlock{blockType: "List", items: []string{"backup", "host-1"}},
+			sendBlock{blockType: "Image", url: "https://example.com/backup.png", alt: "backup"},
+		},
+	}
+	exceptOptions(t, expected, &options, err)
+}
+
+func Test_applyTemplates_WithoutVars(t *testing.T) {
+	t.Setenv("JOB_NAME", "backup")
+	options, err := parseSendArgs([]string{"--subject", "Job {{env \"JOB_NAME\"}} on {{now | date \"2006\"}}", "--code-block", "{{env \"JOB_NAME\"}}"})
+	expectNoError(t, err)
+	expectNoError(t, applyTemplates(options))
+	exceptStringsEqual(t, "Job backup on "+time.Now().Format("2006"), options.subject)
+	exceptStringsEqual(t, "backup", options.blocks[0].text)
+}
+
+func Test_applyTemplates_NoTemplates(t *testing.T) {
+	options, err := parseSendArgs([]string{"--no-templates", "--subject", "Deploy {{ failed", "--code-block", "{{ .Values.image }}"})
+	expectNoError(t, err)
+	expectNoError(t, applyTemplates(options))
+	exceptStringsEqual(t, "Deploy {{ failed", options.subject)
+	exceptStringsEqual(t, "{{ .Values.image }}", options.blocks[0].text)
+
+	_, err = parseSendArgs([]string{"--no-templates", "--var", "a=b"})
+	expectError(t, "--no-templates can't be combined with --var or --vars-file", err)
+}
+
+func Test_renderSendTemplates_Error(t *testing.T) {
+	options := sendOptions{
+		subject: "foobar",
+		blocks: []sendBlock{
+			sendBlock{blockType: "Paragraph", text: "{{.host"},
+		},
+	}
+	err := renderSendTemplates(&options, nil)
+	expectError(t, "could not render template: template: blocks[0].text:1: unclosed action", err)
+	expectExitCode(t, 3, err)
+}
+
+func Test_loadVarsFile(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "vars.env")
+	ioutil.WriteFile(envPath, []byte("# comment\nhost=host-1\njob=backup\nquery=a=b\n"), 0644)
+	yamlPath := filepath.Join(dir, "vars.yaml")
+	ioutil.WriteFile(yamlPath, []byte("host: host-2\n"), 0644)
+	badPath := filepath.Join(dir, "bad.env")
+	ioutil.WriteFile(badPath, []byte("host=host-1\nfoobar\n"), 0644)
+
+	vars, err := loadVarsFile(envPath)
+	expectNoError(t, err)
+	expected := map[string]string{"host": "host-1", "job": "backup", "query": "a=b"}
+	if !reflect.DeepEqual(expected, vars) {
+		t.Errorf("vars: expected=%s actual=%s", expected, vars)
+	}
+
+	vars, err = loadVarsFile(yamlPath)
+	expectNoError(t, err)
+	expected = map[string]string{"host": "host-2"}
+	if !reflect.DeepEqual(expected, vars) {
+		t.Errorf("vars: expected=%s actual=%s", expected, vars)
+	}
+
+	_, err = loadVarsFile(badPath)
+	expectError(t, badPath+":2: invalid variable 'foobar' (should be key=value)", err)
+}
+
+func Test_parseSendArgs_Vars(t *testing.T) {
+	args := []string{
+		"--var", "host=host-1",
+		"--var", "job=backup",
+		"--vars-file", "vars.env",
+		"--allow-missing-vars",
+	}
+	actual, err := parseSendArgs(args)
+	expectNoError(t, err)
+	expected := map[string]string{"host": "host-1", "job": "backup"}
+	if !reflect.DeepEqual(expected, actual.vars) {
+		t.Errorf("vars: expected=%s actual=%s", expected, actual.vars)
+	}
+	if !reflect.DeepEqual([]string{"vars.env"}, actual.varsFiles) {
+		t.Errorf("varsFiles: expected=%s actual=%s", []string{"vars.env"}, actual.varsFiles)
+	}
+	if !actual.allowMissingVars {
+		t.Errorf("allowMissingVars: expected=true actual=false")
+	}
+}
+
+func Test_parseSendArgs_InvalidVar(t *testing.T) {
+	_, err := parseSendArgs([]string{"--var", "host"})
+	expectError(t, "invalid variable 'host' (should be key=value) (argument 1)", err)
+}
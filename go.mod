@@ -0,0 +1,3 @@
+module github.com/codeclown/mendsail-cli
+
+go 1.18
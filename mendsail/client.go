@@ -0,0 +1,343 @@
+// Package mendsail is a client for the Mendsail API, which sends emails
+// built from blocks such as headings, paragraphs and code blocks.
+//
+//	client := mendsail.NewClient(mendsail.WithApiKey(apiKey))
+//	result, err := client.Send(ctx, message)
+package mendsail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseUrl = "https://api.mendsail.com/v1"
+const DefaultTimeout = 30 * time.Second
+const DefaultUserAgent = "mendsail-go/1.0"
+
+const retryBaseWait = 500 * time.Millisecond
+
+// RetryPolicy controls how failed requests are retried. Only network errors,
+// timeouts, rate limiting and server errors are retried, with exponential
+// backoff capped at MaxWait.
+type RetryPolicy struct {
+	Retries int
+	MaxWait time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{Retries: 3, MaxWait: 30 * time.Second}
+
+type Client struct {
+	baseUrl    string
+	apiKey     string
+	userAgent  string
+	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+type Option func(client *Client)
+
+// WithBaseUrl sends requests to another API, e.g. a local instance. An empty
+// url keeps the default.
+func WithBaseUrl(url string) Option {
+	return func(client *Client) {
+		if url != "" {
+			client.baseUrl = strings.TrimRight(url, "/")
+		}
+	}
+}
+
+func WithApiKey(apiKey string) Option {
+	return func(client *Client) {
+		client.apiKey = apiKey
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(client *Client) {
+		client.userAgent = userAgent
+	}
+}
+
+// WithHttpClient replaces the default client, which times out after
+// DefaultTimeout.
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+func NewClient(options ...Option) *Client {
+	client := &Client{
+		baseUrl:    DefaultBaseUrl,
+		userAgent:  DefaultUserAgent,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		retry:      DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// BaseUrl returns the API the client sends requests to.
+func (client *Client) BaseUrl() string {
+	return client.baseUrl
+}
+
+type sendConfig struct {
+	idempotencyKey string
+}
+
+type SendOption func(config *sendConfig)
+
+// WithIdempotencyKey sets the key sent with every attempt, so that the API can
+// discard duplicates when a request succeeded but its response was lost. Send
+// generates one if it isn't given; set it to send the same email again later
+// without risking duplicates.
+func WithIdempotencyKey(key string) SendOption {
+	return func(config *sendConfig) {
+		config.idempotencyKey = key
+	}
+}
+
+// Result describes a sent email. Recipients default to everyone the email was
+// addressed to if the API doesn't list them.
+type Result struct {
+	Id         string   `json:"id"`
+	Status     string   `json:"status"`
+	Recipients []string `json:"recipients"`
+	// How many requests were made, including retries.
+	Attempts int `json:"attempts"`
+}
+
+// ApiError is a non-2xx response. The API describes errors as
+// {"error": {"code": ..., "message": ...}}; Code and Message are empty if the
+// body doesn't look like that.
+type ApiError struct {
+	StatusCode int
+	Status     string
+	Code       string
+	Message    string
+	Body       string
+}
+
+func (e *ApiError) Error() string {
+	return "Server returned error: " + e.Status + "\n" + e.Body
+}
+
+func newApiError(resp *http.Response, body []byte) *ApiError {
+	err := &ApiError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	var parsed struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		err.Code = parsed.Error.Code
+		err.Message = parsed.Error.Message
+	}
+	return err
+}
+
+// NetworkError is a request that got no response at all.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the request timed out.
+func (e *NetworkError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.Err, &netErr) && netErr.Timeout()
+}
+
+func NewIdempotencyKey() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		// Extremely unlikely; fall back to something unique enough per message.
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+// NewRequest builds the request Send makes for message, e.g. to show it
+// without sending it.
+func (client *Client) NewRequest(ctx context.Context, message *Message, idempotencyKey string) (*http.Request, error) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return client.newRequest(ctx, payload, idempotencyKey)
+}
+
+func (client *Client) newRequest(ctx context.Context, payload []byte, idempotencyKey string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", client.baseUrl+"/emails", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", client.userAgent)
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("x-api-key", client.apiKey)
+	if idempotencyKey != "" {
+		req.Header.Set("idempotency-key", idempotencyKey)
+	}
+	return req, nil
+}
+
+// Replaced in tests to avoid waiting.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryWait returns how long to wait before retry number attempt (starting
+// from 0): exponential backoff with jitter, or what the server asked for in
+// Retry-After, capped at maxWait either way.
+func retryWait(attempt int, resp *http.Response, maxWait time.Duration) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get("retry-after")); ok {
+			if wait > maxWait {
+				return maxWait
+			}
+			return wait
+		}
+	}
+	wait := retryBaseWait << uint(attempt)
+	if wait > maxWait || wait <= 0 {
+		wait = maxWait
+	}
+	// Equal jitter: somewhere between half and all of the backoff.
+	half := wait / 2
+	return half + time.Duration(mathrand.Int63n(int64(half)+1))
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// Send sends message, retrying according to the client's retry policy. The
+// returned result is never nil, so that Attempts is available on failure too.
+// Errors are either an *ApiError or a *NetworkError, unless ctx was canceled.
+func (client *Client) Send(ctx context.Context, message *Message, options ...SendOption) (*Result, error) {
+	config := sendConfig{}
+	for _, option := range options {
+		option(&config)
+	}
+	if config.idempotencyKey == "" {
+		config.idempotencyKey = NewIdempotencyKey()
+	}
+
+	result := &Result{}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return result, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= client.retry.Retries; attempt++ {
+		req, err := client.newRequest(ctx, payload, config.idempotencyKey)
+		if err != nil {
+			return result, err
+		}
+
+		result.Attempts++
+		resp, err := client.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			lastErr = &NetworkError{err}
+			if attempt < client.retry.Retries {
+				if err := sleep(ctx, retryWait(attempt, nil, client.retry.MaxWait)); err != nil {
+					return result, err
+				}
+			}
+			continue
+		}
+
+		// A truncated body still says enough in an error.
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			attempts := result.Attempts
+			json.Unmarshal(body, result)
+			result.Attempts = attempts
+			if len(result.Recipients) == 0 {
+				result.Recipients = message.Recipients()
+			}
+			return result, nil
+		}
+
+		lastErr = newApiError(resp, body)
+		if !isRetryableStatus(resp.StatusCode) {
+			return result, lastErr
+		}
+		if attempt < client.retry.Retries {
+			if err := sleep(ctx, retryWait(attempt, resp, client.retry.MaxWait)); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, lastErr
+}
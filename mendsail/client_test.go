@@ -0,0 +1,279 @@
+package mendsail
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mockSleep(t *testing.T) *[]time.Duration {
+	waits := make([]time.Duration, 0)
+	original := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() {
+		sleep = original
+	})
+	return &waits
+}
+
+func testClient(url string, options ...Option) *Client {
+	return NewClient(append([]Option{
+		WithBaseUrl(url),
+		WithApiKey("api-key-123"),
+		WithHttpClient(&http.Client{Timeout: time.Second}),
+		WithRetryPolicy(RetryPolicy{Retries: 3, MaxWait: 10 * time.Second}),
+	}, options...)...)
+}
+
+func testMessage() *Message {
+	return &Message{To: []string{"foo@example.com"}, Cc: []string{"bar@example.com"}, Subject: "Test"}
+}
+
+func Test_Send_Success(t *testing.T) {
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		w.WriteHeader(200)
+		w.Write([]byte(`{"id":"msg_123","status":"accepted","attempts":99}`))
+	}))
+	defer server.Close()
+
+	result, err := testClient(server.URL+"/").Send(context.Background(), testMessage(), WithIdempotencyKey("key-123"))
+	expectNoError(t, err)
+	exceptStringsEqual(t, "/emails", request.URL.Path)
+	exceptStringsEqual(t, "api-key-123", request.Header.Get("x-api-key"))
+	exceptStringsEqual(t, "application/json", request.Header.Get("content-type"))
+	exceptStringsEqual(t, "key-123", request.Header.Get("idempotency-key"))
+	exceptStringsEqual(t, DefaultUserAgent, request.Header.Get("user-agent"))
+	exceptStringsEqual(t, "msg_123", result.Id)
+	exceptStringsEqual(t, "accepted", result.Status)
+	if result.Attempts != 1 {
+		t.Errorf("attempts: expected=%d actual=%d", 1, result.Attempts)
+	}
+	expected := []string{"foo@example.com", "bar@example.com"}
+	if !reflect.DeepEqual(expected, result.Recipients) {
+		t.Errorf("recipients: expected=%s actual=%s", expected, result.Recipients)
+	}
+}
+
+func Test_Send_GeneratesIdempotencyKey(t *testing.T) {
+	keys := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("idempotency-key"))
+		w.Write([]byte(`{"recipients":["foo@example.com"]}`))
+	}))
+	defer server.Close()
+
+	client := testClient(server.URL)
+	result, err := client.Send(context.Background(), testMessage())
+	expectNoError(t, err)
+	_, err = client.Send(context.Background(), testMessage())
+	expectNoError(t, err)
+	if len(keys) != 2 || len(keys[0]) != 36 || keys[0] == keys[1] {
+		t.Errorf("idempotency keys: expected two unique UUIDs, actual=%s", keys)
+	}
+	if !reflect.DeepEqual([]string{"foo@example.com"}, result.Recipients) {
+		t.Errorf("recipients: expected=[foo@example.com] actual=%s", result.Recipients)
+	}
+}
+
+func Test_Send_RetriesWithSameIdempotencyKey(t *testing.T) {
+	waits := mockSleep(t)
+	keys := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("idempotency-key"))
+		if len(keys) < 3 {
+			w.WriteHeader(502)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	result, err := testClient(server.URL).Send(context.Background(), testMessage(), WithIdempotencyKey("key-123"))
+	expectNoError(t, err)
+	if len(keys) != 3 || keys[0] != "key-123" || keys[2] != "key-123" {
+		t.Errorf("idempotency keys: expected 3x key-123, actual=%s", keys)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("attempts: expected=%d actual=%d", 3, result.Attempts)
+	}
+	if len(*waits) != 2 {
+		t.Fatalf("waits: expected=%d actual=%d", 2, len(*waits))
+	}
+	if (*waits)[0] < 250*time.Millisecond || (*waits)[0] > 500*time.Millisecond {
+		t.Errorf("waits[0]: expected between 250ms and 500ms, actual=%s", (*waits)[0])
+	}
+	if (*waits)[1] < 500*time.Millisecond || (*waits)[1] > time.Second {
+		t.Errorf("waits[1]: expected between 500ms and 1s, actual=%s", (*waits)[1])
+	}
+}
+
+func Test_Send_GivesUpAfterRetries(t *testing.T) {
+	waits := mockSleep(t)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts += 1
+		w.WriteHeader(500)
+		w.Write([]byte("oops"))
+	}))
+	defer server.Close()
+
+	result, err := testClient(server.URL).Send(context.Background(), testMessage())
+	expectError(t, "Server returned error: 500 Internal Server Error\noops", err)
+	if attempts != 4 || result.Attempts != 4 {
+		t.Errorf("attempts: expected=%d actual=%d (%d)", 4, attempts, result.Attempts)
+	}
+	if len(*waits) != 3 {
+		t.Errorf("waits: expected=%d actual=%d", 3, len(*waits))
+	}
+}
+
+func Test_Send_DoesNotRetryClientErrors(t *testing.T) {
+	mockSleep(t)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts += 1
+		w.WriteHeader(422)
+		w.Write([]byte(`{"error":{"code":"invalid_recipient","message":"Recipient is not verified"}}`))
+	}))
+	defer server.Close()
+
+	_, err := testClient(server.URL).Send(context.Background(), testMessage())
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err: expected *ApiError, actual=%v", err)
+	}
+	if apiErr.StatusCode != 422 {
+		t.Errorf("status: expected=%d actual=%d", 422, apiErr.StatusCode)
+	}
+	exceptStringsEqual(t, "invalid_recipient", apiErr.Code)
+	exceptStringsEqual(t, "Recipient is not verified", apiErr.Message)
+	if attempts != 1 {
+		t.Errorf("attempts: expected=%d actual=%d", 1, attempts)
+	}
+}
+
+func Test_Send_HonorsRetryAfter(t *testing.T) {
+	waits := mockSleep(t)
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts += 1
+		if attempts == 1 {
+			w.Header().Set("retry-after", "7")
+			w.WriteHeader(429)
+			return
+		}
+		if attempts == 2 {
+			w.Header().Set("retry-after", "120")
+			w.WriteHeader(503)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	_, err := testClient(server.URL).Send(context.Background(), testMessage())
+	expectNoError(t, err)
+	if len(*waits) != 2 || (*waits)[0] != 7*time.Second || (*waits)[1] != 10*time.Second {
+		t.Errorf("waits: expected=[7s 10s] actual=%s", *waits)
+	}
+}
+
+func Test_Send_RetriesNetworkErrors(t *testing.T) {
+	waits := mockSleep(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	_, err := testClient(url, WithRetryPolicy(RetryPolicy{Retries: 1})).Send(context.Background(), testMessage())
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || netErr.Timeout() {
+		t.Fatalf("err: expected connection error, actual=%v", err)
+	}
+	if len(*waits) != 1 {
+		t.Errorf("waits: expected=%d actual=%d", 1, len(*waits))
+	}
+}
+
+func Test_Send_Timeout(t *testing.T) {
+	mockSleep(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := testClient(server.URL,
+		WithHttpClient(&http.Client{Timeout: 50 * time.Millisecond}),
+		WithRetryPolicy(RetryPolicy{}))
+	_, err := client.Send(context.Background(), testMessage())
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("err: expected timeout, actual=%v", err)
+	}
+}
+
+func Test_Send_Canceled(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts += 1
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	original := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return original(ctx, d)
+	}
+	defer func() { sleep = original }()
+
+	_, err := testClient(server.URL).Send(ctx, testMessage())
+	if err != context.Canceled {
+		t.Errorf("err: expected=%v actual=%v", context.Canceled, err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts: expected=%d actual=%d", 1, attempts)
+	}
+}
+
+func Test_NewRequest(t *testing.T) {
+	client := NewClient(WithApiKey("api-key-123"), WithUserAgent("test/1.0"))
+	req, err := client.NewRequest(context.Background(), testMessage(), "key-1")
+	expectNoError(t, err)
+	exceptStringsEqual(t, "POST", req.Method)
+	exceptStringsEqual(t, DefaultBaseUrl+"/emails", req.URL.String())
+	exceptStringsEqual(t, "test/1.0", req.Header.Get("user-agent"))
+	exceptStringsEqual(t, "key-1", req.Header.Get("idempotency-key"))
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	wait, ok := parseRetryAfter("3")
+	if !ok || wait != 3*time.Second {
+		t.Errorf("parseRetryAfter(3): expected=3s actual=%s ok=%t", wait, ok)
+	}
+	_, ok = parseRetryAfter("soon")
+	if ok {
+		t.Errorf("parseRetryAfter(soon): expected ok=false")
+	}
+	wait, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || wait < 59*time.Minute {
+		t.Errorf("parseRetryAfter(date): expected ~1h actual=%s ok=%t", wait, ok)
+	}
+}
+
+func Test_NewIdempotencyKey(t *testing.T) {
+	first := NewIdempotencyKey()
+	second := NewIdempotencyKey()
+	if len(first) != 36 || first == second {
+		t.Errorf("NewIdempotencyKey: expected unique UUIDs, actual=%s %s", first, second)
+	}
+}
@@ -0,0 +1,198 @@
+package mendsail
+
+import "encoding/base64"
+
+// An email is a list of blocks, rendered by Mendsail in the order given. Use
+// the constructors below to build them, e.g.
+//
+//	message := &mendsail.Message{
+//		To:      []string{"you@example.com"},
+//		Subject: "Error in cronjob.sh",
+//		Blocks: []mendsail.Block{
+//			mendsail.Heading("Data processing failed"),
+//			mendsail.CodeBlock(output),
+//		},
+//	}
+
+const (
+	BlockTypeHeading   = "Heading"
+	BlockTypeParagraph = "Paragraph"
+	BlockTypeList      = "List"
+	BlockTypeImage     = "Image"
+	BlockTypeCodeBlock = "CodeBlock"
+	BlockTypeAlert     = "Alert"
+	BlockTypeLink      = "Link"
+	BlockTypeButton    = "Button"
+	BlockTypeTable     = "Table"
+	BlockTypeKeyValue  = "KeyValue"
+	BlockTypeDivider   = "Divider"
+	BlockTypeQuote     = "Quote"
+	BlockTypeSpacer    = "Spacer"
+)
+
+// BlockTypes lists every block type above, which a test checks.
+var BlockTypes = []string{
+	BlockTypeHeading,
+	BlockTypeParagraph,
+	BlockTypeList,
+	BlockTypeImage,
+	BlockTypeCodeBlock,
+	BlockTypeAlert,
+	BlockTypeLink,
+	BlockTypeButton,
+	BlockTypeTable,
+	BlockTypeKeyValue,
+	BlockTypeDivider,
+	BlockTypeQuote,
+	BlockTypeSpacer,
+}
+
+// Styles for alerts and buttons. An empty style leaves it to Mendsail.
+const (
+	StyleSuccess = "success"
+	StyleWarning = "warning"
+	StyleDanger  = "danger"
+	StyleInfo    = "info"
+)
+
+// Sizes for spacers. An empty size leaves it to Mendsail.
+const (
+	SizeSmall  = "small"
+	SizeMedium = "medium"
+	SizeLarge  = "large"
+)
+
+type Block struct {
+	BlockType string   `json:"type"`
+	Text      string   `json:"text,omitempty"`
+	Items     []string `json:"items,omitempty"`
+	Url       string   `json:"url,omitempty"`
+	Alt       string   `json:"alt,omitempty"`
+	Width     int      `json:"width,omitempty"`
+	Style     string   `json:"style,omitempty"`
+	Ghost     bool     `json:"ghost,omitempty"`
+	// Every row of a table has one cell per header.
+	Headers []string   `json:"headers,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+	Pairs   []Pair     `json:"pairs,omitempty"`
+	Cite    string     `json:"cite,omitempty"`
+	Size    string     `json:"size,omitempty"`
+}
+
+// Pair is one entry of a KeyValue block.
+type Pair struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type Message struct {
+	// To is always sent as an array, even with a single recipient. Before Cc,
+	// Bcc and ReplyTo were added, it was sent as a plain string.
+	To          []string     `json:"to"`
+	Cc          []string     `json:"cc,omitempty"`
+	Bcc         []string     `json:"bcc,omitempty"`
+	ReplyTo     string       `json:"replyTo,omitempty"`
+	Subject     string       `json:"subject"`
+	Blocks      []Block      `json:"blocks"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a file sent along with the message. Content is base64
+// encoded, see NewAttachment. An attachment with a ContentId is shown inline
+// by an Image block with the url "cid:" + ContentId instead.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+	ContentId   string `json:"contentId,omitempty"`
+}
+
+// NewAttachment attaches content as a file with the given name and MIME type.
+func NewAttachment(filename string, contentType string, content []byte) Attachment {
+	return Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Content:     base64.StdEncoding.EncodeToString(content),
+	}
+}
+
+// NewInlineAttachment attaches an image to be shown by Image(ContentUrl(contentId), ...).
+func NewInlineAttachment(filename string, contentType string, contentId string, content []byte) Attachment {
+	attachment := NewAttachment(filename, contentType, content)
+	attachment.ContentId = contentId
+	return attachment
+}
+
+// ContentUrl is the url of the inline attachment with the given content ID.
+func ContentUrl(contentId string) string {
+	return "cid:" + contentId
+}
+
+// Recipients returns everyone the message is addressed to.
+func (message *Message) Recipients() []string {
+	return append(append(append([]string{}, message.To...), message.Cc...), message.Bcc...)
+}
+
+func Heading(text string) Block {
+	return Block{BlockType: BlockTypeHeading, Text: text}
+}
+
+func Paragraph(text string) Block {
+	return Block{BlockType: BlockTypeParagraph, Text: text}
+}
+
+func List(items ...string) Block {
+	return Block{BlockType: BlockTypeList, Items: items}
+}
+
+// Image shows the image at url. alt and width (in pixels) are optional.
+func Image(url string, alt string, width int) Block {
+	return Block{BlockType: BlockTypeImage, Url: url, Alt: alt, Width: width}
+}
+
+func CodeBlock(text string) Block {
+	return Block{BlockType: BlockTypeCodeBlock, Text: text}
+}
+
+func Alert(text string, style string) Block {
+	return Block{BlockType: BlockTypeAlert, Text: text, Style: style}
+}
+
+// Link shows text linking to url, or the url itself if text is empty.
+func Link(url string, text string) Block {
+	if text == "" {
+		text = url
+	}
+	return Block{BlockType: BlockTypeLink, Url: url, Text: text}
+}
+
+// Button is a call-to-action link. A ghost button only has an outline.
+func Button(url string, text string, style string, ghost bool) Block {
+	return Block{BlockType: BlockTypeButton, Url: url, Text: text, Style: style, Ghost: ghost}
+}
+
+// Table lays out rows under headers. Every row should have as many cells as
+// there are headers.
+func Table(headers []string, rows ...[]string) Block {
+	return Block{BlockType: BlockTypeTable, Headers: headers, Rows: rows}
+}
+
+// KeyValue lists pairs such as "Host: web-1" in the given order.
+func KeyValue(pairs ...Pair) Block {
+	return Block{BlockType: BlockTypeKeyValue, Pairs: pairs}
+}
+
+// Divider is a horizontal line between sections.
+func Divider() Block {
+	return Block{BlockType: BlockTypeDivider}
+}
+
+// Quote shows text as a quotation. cite, naming the source, is optional.
+func Quote(text string, cite string) Block {
+	return Block{BlockType: BlockTypeQuote, Text: text, Cite: cite}
+}
+
+// Spacer is vertical whitespace of the given size.
+func Spacer(size string) Block {
+	return Block{BlockType: BlockTypeSpacer, Size: size}
+}
@@ -0,0 +1,104 @@
+package mendsail
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Message_Json(t *testing.T) {
+	message := Message{
+		To:      []string{"foo@example.com"},
+		Subject: "Test",
+		Blocks: []Block{
+			Heading("Title"),
+			Paragraph("Text"),
+			List("a", "b"),
+			Image("https://example.com/a.png", "Graph", 300),
+			CodeBlock("x := 1"),
+			Alert("Careful", StyleWarning),
+			Link("https://example.com", ""),
+			Button("https://example.com", "Open", StyleSuccess, true),
+			Table([]string{"Host", "Disk"}, []string{"a", "91%"}),
+			KeyValue(Pair{"Host", "a"}, Pair{"Exit status", "0"}),
+			Divider(),
+			Quote("Ship it", "Release notes"),
+			Spacer(SizeLarge),
+		},
+	}
+	actual, err := json.Marshal(message)
+	expectNoError(t, err)
+	expected := `{"to":["foo@example.com"],"subject":"Test","blocks":[` +
+		`{"type":"Heading","text":"Title"},` +
+		`{"type":"Paragraph","text":"Text"},` +
+		`{"type":"List","items":["a","b"]},` +
+		`{"type":"Image","url":"https://example.com/a.png","alt":"Graph","width":300},` +
+		`{"type":"CodeBlock","text":"x := 1"},` +
+		`{"type":"Alert","text":"Careful","style":"warning"},` +
+		`{"type":"Link","text":"https://example.com","url":"https://example.com"},` +
+		`{"type":"Button","text":"Open","url":"https://example.com","style":"success","ghost":true},` +
+		`{"type":"Table","headers":["Host","Disk"],"rows":[["a","91%"]]},` +
+		`{"type":"KeyValue","pairs":[{"key":"Host","value":"a"},{"key":"Exit status","value":"0"}]},` +
+		`{"type":"Divider"},` +
+		`{"type":"Quote","text":"Ship it","cite":"Release notes"},` +
+		`{"type":"Spacer","size":"large"}]}`
+	exceptStringsEqual(t, expected, string(actual))
+
+	// The message above has one block of every type.
+	if len(message.Blocks) != len(BlockTypes) {
+		t.Fatalf("BlockTypes: expected=%d actual=%d", len(message.Blocks), len(BlockTypes))
+	}
+	for i, block := range message.Blocks {
+		exceptStringsEqual(t, block.BlockType, BlockTypes[i])
+	}
+}
+
+// BlockTypes must list every BlockType* constant, in the order they're declared.
+func Test_BlockTypes_EveryConstant(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "message.go", nil, 0)
+	expectNoError(t, err)
+	constants := make([]string, 0)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "BlockType") {
+					continue
+				}
+				text, err := strconv.Unquote(value.Values[i].(*ast.BasicLit).Value)
+				expectNoError(t, err)
+				constants = append(constants, text)
+			}
+		}
+	}
+	if !reflect.DeepEqual(constants, BlockTypes) {
+		t.Errorf("BlockTypes: expected=%v actual=%v", constants, BlockTypes)
+	}
+}
+
+func Test_Message_JsonAttachments(t *testing.T) {
+	message := Message{
+		To:      []string{"foo@example.com"},
+		Subject: "Test",
+		Blocks:  []Block{Image(ContentUrl("graph"), "Graph", 0)},
+		Attachments: []Attachment{
+			NewAttachment("report.txt", "text/plain", []byte("hello")),
+			NewInlineAttachment("graph.png", "image/png", "graph", []byte("png")),
+		},
+	}
+	actual, err := json.Marshal(message)
+	expectNoError(t, err)
+	expected := `{"to":["foo@example.com"],"subject":"Test","blocks":[{"type":"Image","url":"cid:graph","alt":"Graph"}],` +
+		`"attachments":[{"filename":"report.txt","contentType":"text/plain","content":"aGVsbG8="},` +
+		`{"filename":"graph.png","contentType":"image/png","content":"cG5n","contentId":"graph"}]}`
+	exceptStringsEqual(t, expected, string(actual))
+}